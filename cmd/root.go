@@ -17,9 +17,11 @@ package cmd
 import (
 	"context"
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/pprof"
@@ -27,9 +29,11 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -44,6 +48,14 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/attribute"
+	ocbridge "go.opentelemetry.io/otel/bridge/opencensus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 var (
@@ -89,6 +101,38 @@ type Command struct {
 	logger  alloydb.Logger
 	dialer  alloydb.Dialer
 	cleanup func() error
+
+	// validateOnly configures the Command to print the resolved
+	// configuration and exit without starting the Proxy.
+	validateOnly bool
+
+	// tokenStdin configures the Command to read the bearer token from
+	// standard input.
+	tokenStdin bool
+
+	// result records the outcome of a run started with RunWithResult.
+	result *RunResult
+}
+
+// RunResult describes a run of the Proxy started with RunWithResult.
+type RunResult struct {
+	// Addrs holds the address of each listener the Proxy bound. It is empty
+	// if the Proxy failed to start or serves instances with FUSE.
+	Addrs []string
+	// Instances is the number of instances the Proxy was configured with.
+	Instances int
+	// StartupErr is the error that prevented the Proxy from starting, if
+	// any.
+	StartupErr error
+}
+
+// RunWithResult runs the Command until it exits, like ExecuteContext, and
+// reports what the Proxy did while it ran. The returned RunResult is never
+// nil, even when the error is not.
+func (c *Command) RunWithResult(ctx context.Context) (*RunResult, error) {
+	c.result = &RunResult{}
+	err := c.ExecuteContext(ctx)
+	return c.result, err
 }
 
 // Option is a function that configures a Command.
@@ -101,6 +145,20 @@ func WithLogger(l alloydb.Logger) Option {
 	}
 }
 
+// WithContext configures the Command to run with ctx as its base context.
+// Canceling ctx shuts down the Proxy as if it received SIGINT. A context
+// passed to ExecuteContext or RunWithResult takes precedence.
+func WithContext(ctx context.Context) Option {
+	return func(c *Command) {
+		// Options run again when the configuration is loaded, after
+		// ExecuteContext has set its context, so keep any context already
+		// set.
+		if c.Context() == nil {
+			c.SetContext(ctx)
+		}
+	}
+}
+
 // WithDialer configures the Command to use the provided dialer to connect to
 // AlloyDB instances.
 func WithDialer(d alloydb.Dialer) Option {
@@ -173,11 +231,36 @@ Starting the Proxy
   engine, the first will be started on the default port and subsequent
   instances will be incremented from there (e.g., 5432, 5433, 5434, etc.) To
   disable this behavior, use the --port flag. All subsequent listeners will
-  increment from the provided value.
+  increment from the provided value. To have the operating system assign an
+  available port to each listener, use --port 0. The resolved address of
+  each listener is reported in the startup logs.
 
   All socket listeners use the localhost network interface. To override this
   behavior, use the --address flag.
 
+  When using --unix-socket, the proxy names each socket directory after the
+  lowercased instance URI (e.g., project.region.cluster.instance). As a
+  result, instance URIs that differ only by case share a socket path. To
+  preserve the case of instance URIs, use the
+  --disable-instance-uri-lowercasing flag. Note that case-insensitive file
+  systems (e.g., the Windows and macOS defaults) will still treat such paths
+  as the same, and the second listener will fail to start.
+
+  To serve both local clients over a Unix socket and remote clients over TCP,
+  use --dual-listener together with --unix-socket. Each instance then gets a
+  Unix socket and a TCP listener configured by --address and --port.
+
+  On Windows, the --named-pipe flag replaces TCP listeners with a named pipe
+  for each instance, e.g., \\.\pipe\alloydb-project.region.cluster.instance.
+  Instances that set their own address or port still use TCP.
+
+  To spread load across backend nodes, use --max-connection-lifetime to
+  recycle long-lived connections. Once a connection has been open longer
+  than the provided duration (e.g., 1h), the proxy forcibly closes it after
+  forwarding any data in flight. Clients are disconnected without warning and
+  must reconnect. When using a connection pool, configure the pool's maximum
+  connection lifetime below this value to avoid disruptions.
+
 Instance Level Configuration
 
   The proxy supports overriding configuration on an instance-level with an
@@ -206,6 +289,50 @@ Instance Level Configuration
       ./alloydb-auth-proxy \
           'projects/PROJECT/locations/REGION/clusters/CLUSTER/instances/INSTANCE1?unix-socket-path=/path/to/socket'
 
+  To limit how long the proxy waits to connect to one instance, set the
+  connect-timeout query param to a duration (e.g., connect-timeout=5s). It
+  overrides --dial-timeout for that instance only.
+
+  To trace connections to one instance at a different rate, set the
+  trace-sample-rate query param (e.g., trace-sample-rate=100 to trace 1/100
+  connections). It overrides --telemetry-sample-rate for that instance's
+  connections when exporting traces with --telemetry-project.
+
+  The proxy connects to each instance's private IP address by default. The
+  public-ip, psc, and private-ip query params take precedence over the
+  --public-ip and --psc flags. Setting public-ip=false or psc=false only
+  disables that flag for the instance. To connect to an instance's private
+  IP regardless of the flags, use private-ip=true. Only one of the
+  --public-ip, --psc, and --private-ip flags may be set, and an instance may
+  set at most one of public-ip, psc, and private-ip to true.
+  For example:
+
+      ./alloydb-auth-proxy --public-ip \
+          'projects/PROJECT/locations/REGION/clusters/CLUSTER/instances/INSTANCE1' \
+          'projects/PROJECT/locations/REGION/clusters/CLUSTER/instances/INSTANCE2?private-ip=true'
+
+Client TLS
+
+  By default, the proxy accepts unencrypted connections from local clients.
+  When binding to a non-localhost address, the proxy may instead require TLS
+  from clients. Provide a PEM encoded certificate and private key with the
+  --client-tls-cert and --client-tls-key flags, e.g.,
+
+      ./alloydb-auth-proxy \
+          --address 0.0.0.0 \
+          --client-tls-cert /path/to/cert.pem \
+          --client-tls-key /path/to/key.pem \
+          'projects/PROJECT/locations/REGION/clusters/CLUSTER/instances/INSTANCE'
+
+  To additionally require clients to present a certificate, provide a PEM
+  encoded CA bundle with the --client-tls-client-ca flag. The proxy refuses
+  any client whose certificate is missing or not signed by one of the CAs.
+
+  When enabled, the proxy refuses any client that does not complete a TLS
+  handshake. Clients must start TLS immediately on connecting, rather than
+  sending a Postgres SSLRequest first. For libpq based clients, use
+  sslnegotiation=direct (Postgres 17 and later).
+
 Automatic IAM Authentication
 
   The Auth Proxy support Automatic IAM Authentication where the Proxy
@@ -239,11 +366,51 @@ Health checks
   returns a 503 status. Optionally supports a min-ready query param (e.g.,
   /readiness?min-ready=3) where the proxy will return a 200 status if the
   proxy can connect successfully to at least min-ready number of instances. If
-  min-ready exceeds the number of registered instances, returns a 400.
+  min-ready exceeds the number of registered instances, returns a 400. The
+  response body reports any error, the number of open connections, and any
+  unreachable instances. To receive the body as JSON, set the request header
+  "Accept: application/json".
+
+  To report the proxy as not ready before it reaches --max-connections, set
+  --readiness-max-connections-pct. For example, with --max-connections 100
+  and --readiness-max-connections-pct 95, /readiness returns a 503 status
+  when more than 95 connections are open, giving load balancers a chance to
+  shift traffic before the proxy refuses connections.
 
   - /liveness: Always returns 200 status. If this endpoint is not responding,
   the proxy is in a bad state and should be restarted.
 
+  - /instances/health: Always returns 200 status. The JSON response body
+  reports, for each instance, the time of the most recent successful and
+  failed connection and the most recent connection error. Both client
+  connections and connection tests (see --run-connection-test) are recorded.
+
+  - /connector-health: Reports, for each instance, the time of the most
+  recent successful and failed certificate refresh and the most recent
+  refresh error. Returns a 503 status when the most recent refresh of any
+  instance failed, and 200 otherwise.
+
+  By default, /readiness does not dial instances itself. To detect
+  unreachable instances independently of how often /readiness is requested,
+  set --health-check-interval (e.g., 30s). The proxy then dials every
+  instance in the background at that interval, and /readiness reports the
+  most recent result, failing until the first check completes. With
+  --health-check-interval set, --readiness-tcp-probe has no effect.
+
+  The --readiness-tcp-probe flag configures /readiness to also open a TCP
+  connection to each instance's server-side proxy, skipping the TLS handshake
+  and authentication. The probe verifies the network path (e.g., VPC
+  reachability) with less overhead than a full connection. An instance is
+  connected to in full once to learn its address, which is also learned from
+  any client connection.
+
+  When the health check server is only needed during startup, such as to
+  gate an init container, set --health-check-on-startup-only. The proxy
+  then stops the health check server, freeing its port, once /startup has
+  returned a 200 status --health-check-startup-successes times (1 by
+  default) or once --health-check-startup-timeout has elapsed. The proxy
+  itself keeps running.
+
   To configure the address, use --http-address. To configure the port, use
   --http-port.
 
@@ -305,6 +472,13 @@ Configuration using environment variables
       ALLOYDB_PROXY_INSTANCE_URI_1=projects/PROJECT/locations/REGION/clusters/CLUSTER/instances/INSTANCE2 \
           ./alloydb-auth-proxy
 
+  Instance URIs set with environment variables accept the same query
+  parameters as instance URIs passed as arguments. Quote the value so the
+  shell does not interpret the query string. For example:
+
+      ALLOYDB_PROXY_INSTANCE_URI='projects/PROJECT/locations/REGION/clusters/CLUSTER/instances/INSTANCE?auto-iam-authn=true&port=6000' \
+          ./alloydb-auth-proxy
+
 Configuration using a configuration file
 
   Instead of using CLI flags, the Proxy may be configured using a configuration
@@ -333,12 +507,20 @@ Configuration using a configuration file
       debug = true
       max-connections = 5
 
+  To check a configuration without starting the Proxy, use --validate-only.
+  The Proxy prints the resolved configuration (combining flags, environment
+  variables, and the configuration file) and exits. If the configuration is
+  invalid, the Proxy exits with an error.
+
+      ./alloydb-auth-proxy --config-file=config.toml --validate-only
+
 Localhost Admin Server
 
   The Proxy includes support for an admin server on localhost. By default,
-  the admin server is not enabled. To enable the server, pass the --debug or
-  --quitquitquit flag. This will start the server on localhost at port 9091.
-  To change the port, use the --admin-port flag.
+  the admin server is not enabled. To enable the server, pass the --debug,
+  --quitquitquit, --manual-start, or --reload-credentials flag. This will
+  start the server on localhost at port 9091. To change the port, use the
+  --admin-port flag.
 
   When --debug is set, the admin server enables Go's profiler available at
   /debug/pprof/.
@@ -350,6 +532,17 @@ Localhost Admin Server
   /quitquitquit. The admin server exits gracefully when it receives a POST
   request at /quitquitquit.
 
+  When --reload-credentials is set, the admin server adds an endpoint at
+  /reload-credentials. A POST request at /reload-credentials re-reads the
+  file from --credentials-file, e.g., after a service account key is
+  rotated. Subsequent requests to the AlloyDB Admin API use the new
+  credentials. Existing connections are unaffected.
+
+  When --manual-start is set, the proxy binds all listeners on startup but
+  does not accept connections until the admin server receives a POST request
+  at /start. A POST request at /stop stops accepting new connections until
+  the next request at /start. Existing connections are unaffected.
+
 Debug logging
 
   On occasion, it can help to enable debug logging which will report on
@@ -357,6 +550,28 @@ Debug logging
 
       ./alloydb-auth-proxy <INSTANCE_URI> --debug-logs
 
+  With debug logging, the proxy also logs its resolved configuration on
+  startup with the token and credentials JSON redacted.
+
+  When running many instances, use --log-instances to limit connection logs
+  to a comma-separated list of instance URIs or short names (e.g.,
+  project.region.cluster.instance). Errors are logged for all instances.
+
+Connection audit log
+
+  To keep an audit trail of connections, use --connection-audit-file. The
+  proxy appends a JSON line to the file when each connection is opened and
+  closed:
+
+      ./alloydb-auth-proxy <INSTANCE_URI> \
+          --connection-audit-file /var/log/alloydb-proxy-audit.log
+
+  Each line includes the time, event (connect or close), instance, connection
+  ID, and client address. Close events also include bytesSent and
+  bytesReceived, the bytes copied to the instance and to the client. The file
+  is only ever appended to, so it may be rotated with a tool that copies and
+  truncates it.
+
 
 Waiting for Startup
 
@@ -394,6 +609,15 @@ Static Connection Info
         "caCert": "<CA cert>"
     }
   }
+
+  To combine instances from several files, pass a comma-separated list of
+  files to --static-connection-info. All files must use the same publicKey
+  and privateKey, and an instance listed in more than one file must have the
+  same connection info in each.
+
+  The Proxy checks each file on startup and exits with an error naming the
+  problem (and the instance, if any) when the file is malformed, is missing
+  a key or certificate, or contains a client certificate that has expired.
 `
 
 var waitHelp = `
@@ -425,10 +649,19 @@ var waitHelp = `
   use:
 
   ./alloydb-auth-proxy wait --max 10s
+
+  To report the result as JSON on stdout, use the --json flag. On success,
+  the command prints {"status":"ready","waited":"3s"}. On timeout, the
+  command prints {"status":"timeout"} and exits with a failure status.
+
+  ./alloydb-auth-proxy wait --json
 `
 
 const envPrefix = "ALLOYDB_PROXY"
 
+// instanceFromEnv returns args with any instance URIs set in the
+// environment appended. Each value is used as is, so it may include query
+// parameters that parseConfig applies to the instance.
 func instanceFromEnv(args []string) []string {
 	// This supports naming the first instance first with:
 	//     INSTANCE_URI
@@ -459,6 +692,7 @@ func instanceFromEnv(args []string) []string {
 
 const (
 	waitMaxFlag     = "max"
+	waitJSONFlag    = "json"
 	httpAddressFlag = "http-address"
 	httpPortFlag    = "http-port"
 )
@@ -474,24 +708,74 @@ func runWaitCmd(c *cobra.Command, _ []string) error {
 		// wait flag name has changed where it was registered.
 		return err
 	}
+	jsonOut, _ := c.Flags().GetBool(waitJSONFlag)
 	c.SilenceUsage = true
 
-	t := time.After(wait)
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(c.Context(), wait)
+	defer cancel()
+	backoff := waitInitialBackoff
 	for {
-		select {
-		case <-t:
-			return errors.New("command failed to complete successfully")
-		default:
-			resp, err := http.Get(addr)
-			if err != nil || resp.StatusCode != http.StatusOK {
-				time.Sleep(time.Second)
-				break
+		if ready(ctx, addr) {
+			if jsonOut {
+				writeWaitStatus(c.OutOrStdout(), waitStatus{
+					Status: "ready",
+					Waited: time.Since(start).Round(time.Millisecond).String(),
+				})
 			}
 			return nil
 		}
+		select {
+		case <-ctx.Done():
+			if jsonOut {
+				writeWaitStatus(c.OutOrStdout(), waitStatus{Status: "timeout"})
+			}
+			return errors.New("command failed to complete successfully")
+		case <-time.After(withJitter(backoff)):
+		}
+		backoff = min(2*backoff, waitMaxBackoff)
 	}
 }
 
+const (
+	// waitInitialBackoff is the first interval between polls of the startup
+	// endpoint.
+	waitInitialBackoff = 100 * time.Millisecond
+	// waitMaxBackoff is the longest interval between polls of the startup
+	// endpoint.
+	waitMaxBackoff = 2 * time.Second
+)
+
+// withJitter returns a random duration between d/2 and d.
+func withJitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// ready reports whether the startup endpoint at addr responds with 200 OK.
+func ready(ctx context.Context, addr string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// waitStatus is the result of the wait command when using --json.
+type waitStatus struct {
+	Status string `json:"status"`
+	Waited string `json:"waited,omitempty"`
+}
+
+func writeWaitStatus(w io.Writer, s waitStatus) {
+	// Encoding a struct of strings does not fail.
+	_ = json.NewEncoder(w).Encode(s)
+}
+
 // NewCommand returns a Command object representing an invocation of the proxy.
 func NewCommand(opts ...Option) *Command {
 	rootCmd := &cobra.Command{
@@ -527,13 +811,22 @@ func NewCommand(opts ...Option) *Command {
 		30*time.Second,
 		"maximum amount of time to wait for startup",
 	)
+	waitFlags.Bool(
+		waitJSONFlag, false,
+		"print the result as JSON to stdout",
+	)
 	rootCmd.AddCommand(waitCmd)
 
 	rootCmd.Args = func(_ *cobra.Command, args []string) error {
 		return loadConfig(c, args, opts)
 	}
 
-	rootCmd.RunE = func(*cobra.Command, []string) error { return runSignalWrapper(c) }
+	rootCmd.RunE = func(*cobra.Command, []string) error {
+		if c.validateOnly {
+			return printConfig(c)
+		}
+		return runSignalWrapper(c)
+	}
 
 	// Flags that apply only to the root command
 	localFlags := rootCmd.Flags()
@@ -547,12 +840,26 @@ func NewCommand(opts ...Option) *Command {
 		"Path to a TOML file containing configuration options.")
 	localFlags.StringVar(&c.conf.OtherUserAgents, "user-agent", "",
 		"Space separated list of additional user agents, e.g. custom-agent/0.0.1")
+	localFlags.StringVar(&c.conf.UserAgentOverride, "user-agent-override", "",
+		`User agent that replaces the default user agent, e.g. custom-agent/0.0.1.
+Unlike --user-agent, the default alloy-db-auth-proxy/<version> is not sent.
+Any --user-agent values are appended to the override.`)
+	localFlags.StringVar(&c.conf.UserAgentSuffix, "user-agent-suffix", "",
+		`Identifier appended to the user agent, e.g., deployment/prod-east, to
+attribute AlloyDB Admin API usage. May contain only letters, digits,
+and the characters . _ / -`)
 	localFlags.StringVarP(&c.conf.Token, "token", "t", "",
 		"Bearer token used for authorization.")
+	localFlags.BoolVar(&c.tokenStdin, "token-stdin", false,
+		`Read the bearer token used for authorization from standard input until
+EOF. Keeps the token out of process arguments and the environment.`)
 	localFlags.StringVarP(&c.conf.CredentialsFile, "credentials-file", "c", "",
 		"Path to a service account key to use for authentication.")
 	localFlags.StringVarP(&c.conf.CredentialsJSON, "json-credentials", "j", "",
 		"Use service account key JSON as a source of IAM credentials.")
+	localFlags.BoolVar(&c.conf.ReloadCredentials, "reload-credentials", false,
+		`Enable a /reload-credentials endpoint on the localhost admin server that
+re-reads --credentials-file on a POST request. Requires --credentials-file.`)
 	localFlags.BoolVarP(&c.conf.GcloudAuth, "gcloud-auth", "g", false,
 		`Use gcloud's user credentials as a source of IAM credentials.
 NOTE: this flag is a legacy feature and generally should not be used.
@@ -561,13 +868,65 @@ Instead prefer Application Default Credentials
 the Proxy will then pick-up automatically.`)
 	localFlags.BoolVarP(&c.conf.StructuredLogs, "structured-logs", "l", false,
 		"Enable structured logs using the LogEntry format")
+	localFlags.StringVar(&c.conf.LogFormat, "log-format", "",
+		`Format of log messages. One of text (the default), json, or logfmt for
+lines of key=value pairs. --structured-logs is the same as --log-format json.`)
+	localFlags.StringVar(&c.conf.LogTimestampFormat, "log-timestamp-format", "",
+		`Format of the timestamp written with each log message. One of rfc3339,
+rfc3339nano, iso8601, unix, unixmilli, unixmicro, unixnano, or a Go time
+layout with the date and time (e.g., "2006-01-02 15:04:05.000"). Defaults
+to the local date and time for standard logs, iso8601 for structured
+logs, and rfc3339 for logfmt logs.`)
+	localFlags.BoolVar(&c.conf.LogSource, "log-source", false,
+		`Add the source file and line that logged each message. Useful when
+debugging, though finding the source adds overhead to every message.`)
 	localFlags.BoolVar(&c.conf.DebugLogs, "debug-logs", false,
 		"Enable debug logging")
+	localFlags.StringSliceVar(&c.conf.LogInstances, "log-instances", nil,
+		`Comma-separated list of instance URIs or short names whose connections
+are logged. Errors are logged for all instances.`)
 	localFlags.Uint64Var(&c.conf.MaxConnections, "max-connections", 0,
 		`Limits the number of connections by refusing any additional connections.
 When this flag is not set, there is no limit.`)
+	localFlags.DurationVar(&c.conf.MaxConnectionsQueue, "max-connections-queue", 0,
+		`Time a new connection waits for an open connection to close once
+--max-connections is reached, before it is refused (e.g., 5s). When this
+flag is not set, such connections are refused immediately.`)
+	localFlags.StringVar(&c.conf.AppendApplicationName, "append-application-name", "",
+		`Value appended to the application_name that clients send when
+connecting, so that queries can be attributed to the Proxy on the server
+(e.g., alloydb-auth-proxy, which turns psql into
+"psql (alloydb-auth-proxy)").`)
+	localFlags.BoolVar(&c.conf.FriendlyMaxConnError, "friendly-max-conn-error", false,
+		`Send a Postgres error to clients refused by --max-connections instead of
+closing the connection without a response.`)
+	localFlags.Float64Var(&c.conf.NewConnectionRate, "new-connection-rate", 0,
+		`Limits the rate of new connections per second. Connections that would
+wait more than a second are refused. When this flag is not set, there is no limit.`)
+	localFlags.IntVar(&c.conf.NewConnectionBurst, "new-connection-burst", 1,
+		"Number of new connections allowed at once before --new-connection-rate applies.")
+	localFlags.BoolVar(&c.conf.NewConnectionRatePerInstance, "new-connection-rate-per-instance", false,
+		"Apply --new-connection-rate to each instance instead of all instances together.")
+	localFlags.DurationVar(&c.conf.StartupDelay, "startup-delay", 0,
+		`Time to wait between starting the listeners of each instance (e.g., 500ms),
+to spread out the load of starting many instances. Defaults to 0s.`)
+	localFlags.DurationVar(&c.conf.MaxStartupTime, "max-startup-time", 0,
+		`Maximum time to wait for the proxy to start (e.g., 2m). If startup takes
+longer, the proxy exits with an error. When this flag is not set, there is no limit.`)
+	localFlags.DurationVar(&c.conf.DialTimeout, "dial-timeout", 30*time.Second,
+		`Maximum time to wait when connecting to an instance for a new client
+connection. Override it for one instance with the connect-timeout query param.`)
+	localFlags.DurationVar(&c.conf.MaxConnectionLifetime, "max-connection-lifetime", 0,
+		`Closes client connections that have been open longer than this duration
+(e.g., 1h), forcing clients to reconnect. When this flag is not set, there is no limit.`)
 	localFlags.DurationVar(&c.conf.WaitBeforeClose, "min-sigterm-delay", 0,
 		`The number of seconds to accept new connections after receiving a TERM
+signal. Defaults to 0s.`)
+	localFlags.BoolVar(&c.conf.RefuseNewOnSigterm, "refuse-new-on-sigterm", false,
+		`Stop accepting new connections as soon as a TERM signal is received,
+instead of after --min-sigterm-delay. Open connections are unaffected.`)
+	localFlags.DurationVar(&c.conf.WaitBeforeCloseOnSigint, "min-sigint-delay", 0,
+		`The number of seconds to accept new connections after receiving an INT
 signal. Defaults to 0s.`)
 	localFlags.DurationVar(&c.conf.WaitOnClose, "max-sigterm-delay", 0,
 		`Maximum amount of time to wait after for any open connections
@@ -577,11 +936,26 @@ the maximum time has passed. Defaults to 0s.`)
 	localFlags.StringVar(&c.conf.APIEndpointURL, "alloydbadmin-api-endpoint",
 		"https://alloydb.googleapis.com",
 		"When set, the proxy uses this host as the base API path.")
+	localFlags.StringVar(&c.conf.AdminAPIProxyURL, "admin-api-proxy-url", "",
+		`URL of an HTTP or SOCKS5 proxy used only for AlloyDB Admin API requests
+(e.g., http://proxy.example.com:3128 or socks5://localhost:1080).`)
 	localFlags.StringVar(&c.conf.FUSEDir, "fuse", "",
 		"Mount a directory at the path using FUSE to access AlloyDB instances.")
 	localFlags.StringVar(&c.conf.FUSETempDir, "fuse-tmp-dir",
 		filepath.Join(os.TempDir(), "alloydb-tmp"),
 		"Temp dir for Unix sockets created with FUSE")
+	localFlags.StringVar(&c.conf.FUSESocketSuffix, "fuse-socket-suffix", "",
+		`Port number used in the name of Postgres sockets created with FUSE,
+e.g., 6432 for .s.PGSQL.6432. Defaults to 5432.`)
+	localFlags.BoolVar(&c.conf.FUSENoAutoconnect, "fuse-no-autoconnect", false,
+		`Only dial FUSE instances in health checks after a client connects.
+Listing the directory or stat-ing a socket path never dials an instance.`)
+	localFlags.Uint64Var(&c.conf.FUSEMaxInstances, "fuse-max-instances", 0,
+		`Maximum number of instances with sockets in the FUSE directory. Once
+reached, connections to further instances are refused. Defaults to no limit.`)
+	localFlags.BoolVar(&c.conf.FUSEFallback, "fuse-fallback", false,
+		`When FUSE is not supported, start listeners for the instance URIs
+provided as arguments instead of failing.`)
 	localFlags.StringVar(&c.conf.ImpersonationChain, "impersonate-service-account", "",
 		`Comma separated list of service accounts to impersonate. Last value
 +is the target account.`)
@@ -590,34 +964,97 @@ the maximum time has passed. Defaults to 0s.`)
 	localFlags.StringVar(&c.conf.TelemetryProject, "telemetry-project", "",
 		"Enable Cloud Monitoring and Cloud Trace integration with the provided project ID.")
 	localFlags.BoolVar(&c.conf.DisableTraces, "disable-traces", false,
-		"Disable Cloud Trace integration (used with telemetry-project)")
+		"Disable Cloud Trace integration (used with telemetry-project or otlp-endpoint)")
 	localFlags.IntVar(&c.conf.TelemetryTracingSampleRate, "telemetry-sample-rate", 10_000,
 		"Configure the denominator of the probabilistic sample rate of traces sent to Cloud Trace\n(e.g., 10,000 traces 1/10,000 calls).")
 	localFlags.BoolVar(&c.conf.DisableMetrics, "disable-metrics", false,
-		"Disable Cloud Monitoring integration (used with telemetry-project)")
+		"Disable Cloud Monitoring integration (used with telemetry-project or otlp-endpoint)")
 	localFlags.StringVar(&c.conf.TelemetryPrefix, "telemetry-prefix", "",
 		"Prefix to use for Cloud Monitoring metrics.")
+	localFlags.StringVar(&c.conf.OTLPEndpoint, "otlp-endpoint", "",
+		`Send metrics and traces to the OpenTelemetry collector at the provided
+host:port using OTLP over gRPC. May not be used with --telemetry-project.`)
+	localFlags.BoolVar(&c.conf.OTLPInsecure, "otlp-insecure", false,
+		"Connect to the OTLP endpoint without TLS (e.g., for a local collector).")
 	localFlags.BoolVar(&c.conf.Prometheus, "prometheus", false,
 		"Enable Prometheus HTTP endpoint /metrics")
 	localFlags.StringVar(&c.conf.PrometheusNamespace, "prometheus-namespace", "",
 		"Use the provided Prometheus namespace for metrics")
+	localFlags.StringVar(&c.conf.MetricsPath, "metrics-path", "/metrics",
+		"Path of the Prometheus HTTP endpoint. Must start with /.")
+	localFlags.StringVar(&c.conf.DeploymentLabel, "deployment-label", "",
+		`Label identifying the environment of the proxy (e.g., prod or staging).
+Added to structured logs and exported metrics.`)
+	localFlags.StringToStringVar(&c.conf.TelemetryLabels, "telemetry-labels", nil,
+		`Comma-separated list of key=value labels added to all exported metrics
+(e.g., team=data,env=prod). Keys must start with a letter or underscore
+and contain only letters, digits, and underscores.`)
 	globalFlags.StringVar(&c.conf.HTTPAddress, "http-address", "localhost",
 		"Address for Prometheus and health check server")
 	globalFlags.StringVar(&c.conf.HTTPPort, "http-port", "9090",
 		"Port for the Prometheus server to use")
+	localFlags.DurationVar(&c.conf.HTTPReadHeaderTimeout, "http-read-header-timeout", 10*time.Second,
+		"Maximum time to read request headers on the HTTP and admin servers")
+	localFlags.DurationVar(&c.conf.HTTPShutdownTimeout, "http-shutdown-timeout", time.Second,
+		"Maximum time the HTTP and admin servers wait for in-flight requests on shutdown")
 	localFlags.BoolVar(&c.conf.Debug, "debug", false,
 		"Enable pprof on the localhost admin server")
 	localFlags.BoolVar(&c.conf.QuitQuitQuit, "quitquitquit", false,
 		"Enable quitquitquit endpoint on the localhost admin server")
+	localFlags.StringVar(&c.conf.ClientTLSCert, "client-tls-cert", "",
+		`Path to a PEM encoded certificate presented to local clients. When set
+with --client-tls-key, listeners refuse unencrypted connections.`)
+	localFlags.StringVar(&c.conf.ClientTLSKey, "client-tls-key", "",
+		"Path to the PEM encoded private key for --client-tls-cert.")
+	localFlags.StringVar(&c.conf.ClientTLSClientCA, "client-tls-client-ca", "",
+		`Path to a PEM encoded CA bundle. When set, local clients must present
+a certificate signed by one of the CAs (used with --client-tls-cert).`)
+	localFlags.StringVar(&c.conf.PIDFile, "pid-file", "",
+		`Path of a file to write the proxy's process ID to once it has started.
+The file is removed on shutdown.`)
+	localFlags.StringVar(&c.conf.ConnectionAuditFile, "connection-audit-file", "",
+		`Path of a file to append a JSON line to for each connection opened and
+closed, recording the time, instance, connection ID, client address, and
+bytes copied on close.`)
+	localFlags.BoolVar(&c.conf.ManualStart, "manual-start", false,
+		`Bind listeners on startup, but accept connections only after a POST
+request to /start on the localhost admin server.`)
 	localFlags.StringVar(&c.conf.AdminPort, "admin-port", "9091",
 		"Port for localhost-only admin server")
 	localFlags.BoolVar(&c.conf.HealthCheck, "health-check", false,
 		`Enables HTTP endpoints /startup, /liveness, and /readiness
 that report on the proxy's health. Endpoints are available on localhost
 only. Uses the port specified by the http-port flag.`)
+	localFlags.BoolVar(&c.conf.QuietHealthChecks, "quiet-healthchecks", false,
+		`Suppress the info messages about starting the health check server and
+enabling admin server endpoints.`)
+	localFlags.DurationVar(&c.conf.HealthCheckInterval, "health-check-interval", 0,
+		`How often to dial each instance in the background for /readiness
+(e.g., 30s). /readiness then reports the most recent result instead of
+dialing on each request. Requires --health-check.`)
+	localFlags.BoolVar(&c.conf.HealthCheckOnStartupOnly, "health-check-on-startup-only", false,
+		`Stop the health check server once /startup has succeeded
+--health-check-startup-successes times or after
+--health-check-startup-timeout, freeing its port (e.g., when gating an
+init container). Requires --health-check.`)
+	localFlags.IntVar(&c.conf.HealthCheckStartupSuccesses, "health-check-startup-successes", 1,
+		`Number of successful /startup responses after which
+--health-check-on-startup-only stops the health check server.`)
+	localFlags.DurationVar(&c.conf.HealthCheckStartupTimeout, "health-check-startup-timeout", 0,
+		`Time after which --health-check-on-startup-only stops the health check
+server, even if /startup has not succeeded (e.g., 5m). Defaults to no timeout.`)
+	localFlags.Uint64Var(&c.conf.ReadinessMaxConnectionsPct, "readiness-max-connections-pct", 0,
+		`Percentage of --max-connections that open connections may reach before
+/readiness fails (e.g., 95). Defaults to failing only at --max-connections.`)
+	localFlags.BoolVar(&c.conf.ReadinessTCPProbe, "readiness-tcp-probe", false,
+		`Configures /readiness to open a TCP connection to each instance without
+a TLS handshake or authentication (used with --health-check).`)
 	localFlags.BoolVar(&c.conf.RunConnectionTest, "run-connection-test", false, `Runs a connection test
 against all specified instances. If an instance is unreachable, the Proxy exits with a failure
 status code.`)
+	localFlags.BoolVar(&c.validateOnly, "validate-only", false,
+		`Validates the configuration, prints the resolved configuration, and
+exits without binding listeners or contacting the AlloyDB Admin API.`)
 	localFlags.BoolVar(&c.conf.LazyRefresh, "lazy-refresh", false,
 		`Configure a lazy refresh where connection info is retrieved only if
 the cached copy has expired. Use this setting in environments where the
@@ -625,27 +1062,81 @@ CPU may be throttled and a background refresh cannot run reliably
 (e.g., Cloud Run)`,
 	)
 	localFlags.StringVar(&c.conf.StaticConnectionInfo, "static-connection-info",
-		"", "JSON file with static connection info. See --help for format.")
+		"", `JSON file with static connection info. See --help for format.
+Accepts a comma-separated list of files, which are merged.`)
 	localFlags.BoolVar(&c.conf.ExitZeroOnSigterm, "exit-zero-sigterm", false,
-		"Exit with 0 exit code when Sigterm received (default is 143)")
+		`Exit with 0 exit code when Sigterm received (default is 143). The
+Proxy still waits for --min-sigterm-delay before exiting.`)
 
 	// Global and per instance flags
 	localFlags.StringVarP(&c.conf.Addr, "address", "a", "127.0.0.1",
 		"(*) Address on which to bind AlloyDB instance listeners.")
+	localFlags.BoolVar(&c.conf.DisableIPv6, "disable-ipv6", false,
+		`Use only IPv4 for TCP listeners and connections to instances, for
+environments that advertise IPv6 but cannot route it.`)
+	localFlags.StringVar(&c.conf.ListenNetwork, "listen-network", "tcp",
+		`Network to bind TCP listeners with: tcp, tcp4, or tcp6. With tcp, an
+unspecified address such as 0.0.0.0 or :: binds both IPv4 and IPv6 where
+available.`)
+	localFlags.IntVar(&c.conf.BindRetries, "bind-retries", 0,
+		`Number of times to retry binding a listener that fails to bind, e.g.,
+while a previous process releases the port. Retries back off from 100ms
+to 2s. When this flag is not set, binding is not retried.`)
 	localFlags.IntVarP(&c.conf.Port, "port", "p", 5432,
-		"(*) Initial port to use for listeners. Subsequent listeners increment from this value.")
+		`(*) Initial port to use for listeners. Subsequent listeners increment from this value.
+Use 0 to have the operating system assign a port to each listener.`)
 	localFlags.StringVarP(&c.conf.UnixSocket, "unix-socket", "u", "",
 		`(*) Enables Unix sockets for all listeners using the provided directory.`)
+	localFlags.BoolVar(&c.conf.DualListener, "dual-listener", false,
+		`Start a TCP listener in addition to the Unix socket for each instance
+(used with --unix-socket). --address and --port configure the TCP listeners.`)
+	localFlags.BoolVar(&c.conf.NamedPipe, "named-pipe", false,
+		`Listen on a Windows named pipe for each instance instead of a TCP port,
+e.g., \\.\pipe\alloydb-project.region.cluster.instance. Windows only.`)
+	localFlags.BoolVar(&c.conf.SkipURIValidation, "skip-uri-validation", false,
+		`Accept instance URIs that do not match the expected format and pass them
+to the AlloyDB connector as is. Listeners for such instances are named after
+the URI with slashes replaced by dots. The connector may still reject a URI
+it cannot parse.`)
+	localFlags.StringToStringVar(&c.conf.RegionAliases, "region-alias", nil,
+		`Comma-separated list of alias=region pairs. The region of an instance
+URI that matches an alias is replaced with the region it stands for
+(e.g., us=us-central1 expands projects/p/locations/us/... to
+projects/p/locations/us-central1/...).`)
+	localFlags.BoolVar(&c.conf.DisableInstanceURILowercasing, "disable-instance-uri-lowercasing", false,
+		"Preserve the case of instance URIs when naming Unix socket directories.")
 	localFlags.BoolVarP(&c.conf.AutoIAMAuthN, "auto-iam-authn", "i", false,
 		"(*) Enables Automatic IAM Authentication for all instances")
 	localFlags.BoolVar(&c.conf.PublicIP, "public-ip", false,
 		"(*) Connect to the public ip address for all instances")
 	localFlags.BoolVar(&c.conf.PSC, "psc", false,
 		"(*) Connect to the PSC endpoint for all instances")
+	localFlags.BoolVar(&c.conf.PrivateIP, "private-ip", false,
+		`(*) Connect to the private ip address for all instances. Private IP is the
+default. As a query param, overrides --public-ip and --psc for the instance.`)
 
 	return c
 }
 
+// resolveLogFormat returns the format of log messages selected by
+// --log-format and --structured-logs.
+func resolveLogFormat(conf *proxy.Config) (string, error) {
+	switch conf.LogFormat {
+	case "", "text", "json", "logfmt":
+	default:
+		return "", newBadCommandError(fmt.Sprintf(
+			"--log-format must be one of text, json, or logfmt, got %q", conf.LogFormat))
+	}
+	if !conf.StructuredLogs {
+		return conf.LogFormat, nil
+	}
+	if conf.LogFormat != "" && conf.LogFormat != "json" {
+		return "", newBadCommandError(fmt.Sprintf(
+			"cannot use --structured-logs with --log-format %v", conf.LogFormat))
+	}
+	return "json", nil
+}
+
 func loadConfig(c *Command, args []string, opts []Option) error {
 	v, err := initViper(c)
 	if err != nil {
@@ -676,12 +1167,31 @@ func loadConfig(c *Command, args []string, opts []Option) error {
 	}
 
 	// Handle logger separately from config
-	if c.conf.StructuredLogs {
-		c.logger, c.cleanup = log.NewStructuredLogger(c.conf.Quiet)
+	tsFormat, err := log.ParseTimestampFormat(c.conf.LogTimestampFormat)
+	if err != nil {
+		return newBadCommandError(err.Error())
+	}
+	logOpts := []log.Option{log.WithTimestampFormat(tsFormat)}
+	if c.conf.LogSource {
+		logOpts = append(logOpts, log.WithSource())
+	}
+	if c.conf.LogTimestampFormat != "" || c.conf.LogSource {
+		c.logger = log.NewStdLogger(os.Stdout, os.Stderr, logOpts...)
+	}
+
+	logFormat, err := resolveLogFormat(c.conf)
+	if err != nil {
+		return err
+	}
+	switch logFormat {
+	case "json":
+		c.logger, c.cleanup = log.NewStructuredLogger(c.conf.Quiet, deploymentLabels(c.conf), logOpts...)
+	case "logfmt":
+		c.logger = log.NewLogfmtLogger(os.Stdout, os.Stderr, deploymentLabels(c.conf), logOpts...)
 	}
 
 	if c.conf.Quiet {
-		c.logger = log.NewStdLogger(io.Discard, os.Stderr)
+		c.logger = log.NewStdLogger(io.Discard, os.Stderr, logOpts...)
 	}
 
 	err = parseConfig(c, c.conf, args)
@@ -689,6 +1199,14 @@ func loadConfig(c *Command, args []string, opts []Option) error {
 		return err
 	}
 
+	// Keep the token and credentials out of any log messages, including
+	// errors that might echo them.
+	c.logger = log.NewRedactingLogger(c.logger, c.conf.Token, c.conf.CredentialsJSON)
+
+	if c.conf.DebugLogs {
+		c.logger.Debugf("Using configuration: %v", c.conf)
+	}
+
 	// The arguments are parsed. Usage is no longer needed.
 	c.SilenceUsage = true
 
@@ -776,6 +1294,21 @@ func instanceFromConfigFile(v *viper.Viper) []string {
 	return args
 }
 
+// listenNetworkConflict returns the flag that prevents binding a TCP
+// listener to ip, or the empty string if ip may be used.
+func listenNetworkConflict(conf *proxy.Config, ip net.IP) string {
+	is4 := ip.To4() != nil
+	switch {
+	case conf.DisableIPv6 && !is4:
+		return "--disable-ipv6"
+	case conf.ListenNetwork == "tcp4" && !is4:
+		return "--listen-network tcp4"
+	case conf.ListenNetwork == "tcp6" && is4:
+		return "--listen-network tcp6"
+	}
+	return ""
+}
+
 func userHasSetLocal(cmd *Command, f string) bool {
 	return cmd.LocalFlags().Lookup(f).Changed
 }
@@ -784,6 +1317,20 @@ func userHasSetGlobal(cmd *Command, f string) bool {
 	return cmd.PersistentFlags().Lookup(f).Changed
 }
 
+// readToken reads a bearer token from r until EOF, trimming surrounding
+// whitespace.
+func readToken(r io.Reader) (string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", newBadCommandError(fmt.Sprintf("failed to read token from standard input: %v", err))
+	}
+	t := strings.TrimSpace(string(b))
+	if t == "" {
+		return "", newBadCommandError("--token-stdin read an empty token from standard input")
+	}
+	return t, nil
+}
+
 func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 	// If no instance connection names were provided AND FUSE isn't enabled,
 	// error.
@@ -791,15 +1338,57 @@ func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 		return newBadCommandError("missing instance uri (e.g., projects/$PROJECTS/locations/$LOCTION/clusters/$CLUSTER/instances/$INSTANCES)")
 	}
 
+	if conf.FUSESocketSuffix != "" {
+		if conf.FUSEDir == "" {
+			return newBadCommandError("cannot specify --fuse-socket-suffix without --fuse")
+		}
+		// libpq finds the socket using the port, so the suffix must be a
+		// valid port number.
+		p, err := strconv.Atoi(conf.FUSESocketSuffix)
+		if err != nil || p < 1 || p > 65535 || strconv.Itoa(p) != conf.FUSESocketSuffix {
+			return newBadCommandError(fmt.Sprintf(
+				"--fuse-socket-suffix must be a port number between 1 and 65535, got %q",
+				conf.FUSESocketSuffix,
+			))
+		}
+	}
+
+	if conf.FUSENoAutoconnect && conf.FUSEDir == "" {
+		return newBadCommandError("cannot specify --fuse-no-autoconnect without --fuse")
+	}
+
+	if conf.FUSEMaxInstances > 0 && conf.FUSEDir == "" {
+		return newBadCommandError("cannot specify --fuse-max-instances without --fuse")
+	}
+
+	if conf.FUSEFallback && conf.FUSEDir == "" {
+		cmd.logger.Infof("Ignoring --fuse-fallback because --fuse was not set")
+	}
+	if conf.FUSEDir != "" {
+		if err := supportsFUSE(); err != nil {
+			if !conf.FUSEFallback {
+				return newBadCommandError(
+					fmt.Sprintf("--fuse is not supported: %v", err),
+				)
+			}
+			if len(args) == 0 {
+				return newBadCommandError(fmt.Sprintf(
+					"--fuse is not supported: %v; --fuse-fallback requires instance URIs", err,
+				))
+			}
+			cmd.logger.Infof(
+				"Warning: --fuse is not supported: %v. Starting listeners for the "+
+					"instance URIs instead because --fuse-fallback is set", err,
+			)
+			conf.FUSEDir = ""
+		}
+	}
 	if conf.FUSEDir != "" {
 		if conf.RunConnectionTest {
 			return newBadCommandError("cannot run connection tests in FUSE mode")
 		}
-
-		if err := proxy.SupportsFUSE(); err != nil {
-			return newBadCommandError(
-				fmt.Sprintf("--fuse is not supported: %v", err),
-			)
+		if conf.RefuseNewOnSigterm {
+			return newBadCommandError("cannot specify --refuse-new-on-sigterm in FUSE mode")
 		}
 	}
 
@@ -807,18 +1396,75 @@ func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 		return newBadCommandError("cannot specify --fuse-tmp-dir without --fuse")
 	}
 
-	if userHasSetLocal(cmd, "address") && userHasSetLocal(cmd, "unix-socket") {
+	if conf.NamedPipe {
+		if err := proxy.SupportsNamedPipes(); err != nil {
+			return newBadCommandError(
+				fmt.Sprintf("--named-pipe is not supported: %v", err),
+			)
+		}
+		if conf.UnixSocket != "" {
+			return newBadCommandError("cannot specify --named-pipe and --unix-socket together")
+		}
+	}
+
+	if conf.DualListener && conf.UnixSocket == "" {
+		return newBadCommandError("cannot specify --dual-listener without --unix-socket")
+	}
+	// With --dual-listener, --address and --port configure the TCP listeners.
+	if userHasSetLocal(cmd, "address") && userHasSetLocal(cmd, "unix-socket") && !conf.DualListener {
 		return newBadCommandError("cannot specify --unix-socket and --address together")
 	}
-	if userHasSetLocal(cmd, "port") && userHasSetLocal(cmd, "unix-socket") {
+	if userHasSetLocal(cmd, "port") && userHasSetLocal(cmd, "unix-socket") && !conf.DualListener {
 		return newBadCommandError("cannot specify --unix-socket and --port together")
 	}
+	if conf.PublicIP && conf.PSC {
+		return newBadCommandError("cannot specify --public-ip and --psc together")
+	}
+	if conf.PrivateIP && conf.PublicIP {
+		return newBadCommandError("cannot specify --private-ip and --public-ip together")
+	}
+	if conf.PrivateIP && conf.PSC {
+		return newBadCommandError("cannot specify --private-ip and --psc together")
+	}
 	// First, validate global config.
 	if ip := net.ParseIP(conf.Addr); ip == nil {
 		return newBadCommandError(fmt.Sprintf("not a valid IP address: %q", conf.Addr))
 	}
+	switch conf.ListenNetwork {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return newBadCommandError(fmt.Sprintf(
+			"--listen-network must be one of tcp, tcp4, or tcp6, got %q", conf.ListenNetwork))
+	}
+	if strings.ContainsRune(conf.AppendApplicationName, 0) {
+		return newBadCommandError("--append-application-name must not contain a NUL character")
+	}
+	if conf.DisableIPv6 && conf.ListenNetwork == "tcp6" {
+		return newBadCommandError("cannot specify --listen-network tcp6 and --disable-ipv6 together")
+	}
+	if f := listenNetworkConflict(conf, net.ParseIP(conf.Addr)); f != "" {
+		return newBadCommandError(
+			fmt.Sprintf("cannot use --address %q with %v", conf.Addr, f))
+	}
 
 	// If more than one auth method is set, error.
+	if cmd.tokenStdin {
+		switch {
+		case conf.Token != "":
+			return newBadCommandError("cannot specify --token-stdin and --token flags at the same time")
+		case conf.CredentialsFile != "":
+			return newBadCommandError("cannot specify --token-stdin and --credentials-file flags at the same time")
+		case conf.CredentialsJSON != "":
+			return newBadCommandError("cannot specify --token-stdin and --json-credentials flags at the same time")
+		case conf.GcloudAuth:
+			return newBadCommandError("cannot specify --token-stdin and --gcloud-auth flags at the same time")
+		}
+		t, err := readToken(cmd.InOrStdin())
+		if err != nil {
+			return err
+		}
+		conf.Token = t
+	}
 	if conf.Token != "" && conf.CredentialsFile != "" {
 		return newBadCommandError("cannot specify --token and --credentials-file flags at the same time")
 	}
@@ -838,6 +1484,20 @@ func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 		return newBadCommandError("cannot specify --json-credentials and --gcloud-auth flags at the same time")
 	}
 
+	if conf.ReloadCredentials && conf.CredentialsFile == "" {
+		return newBadCommandError("cannot specify --reload-credentials without --credentials-file")
+	}
+
+	if conf.ClientTLSCert != "" && conf.ClientTLSKey == "" {
+		return newBadCommandError("cannot specify --client-tls-cert without --client-tls-key")
+	}
+	if conf.ClientTLSKey != "" && conf.ClientTLSCert == "" {
+		return newBadCommandError("cannot specify --client-tls-key without --client-tls-cert")
+	}
+	if conf.ClientTLSClientCA != "" && conf.ClientTLSCert == "" {
+		return newBadCommandError("cannot specify --client-tls-client-ca without --client-tls-cert")
+	}
+
 	if userHasSetLocal(cmd, "alloydbadmin-api-endpoint") {
 		_, err := url.Parse(conf.APIEndpointURL)
 		if err != nil {
@@ -852,31 +1512,200 @@ func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 		cmd.logger.Infof("Using API Endpoint %v", conf.APIEndpointURL)
 	}
 
+	if conf.AdminAPIProxyURL != "" {
+		u, err := url.Parse(conf.AdminAPIProxyURL)
+		if err != nil || u.Host == "" {
+			// The raw value is not echoed because proxy URLs commonly
+			// carry credentials.
+			return newBadCommandError(
+				"provided value for --admin-api-proxy-url is not a valid url",
+			)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return newBadCommandError(fmt.Sprintf(
+				"--admin-api-proxy-url must use the http, https, or socks5 scheme, got %q",
+				u.Scheme,
+			))
+		}
+	}
+
+	if conf.HTTPShutdownTimeout <= 0 {
+		return newBadCommandError("--http-shutdown-timeout must be positive")
+	}
+
 	if userHasSetGlobal(cmd, "http-port") && !userHasSetLocal(cmd, "prometheus") && !userHasSetLocal(cmd, "health-check") {
 		cmd.logger.Infof("Ignoring --http-port because --prometheus or --health-check was not set")
 	}
 
+	if !strings.HasPrefix(conf.MetricsPath, "/") {
+		return newBadCommandError(fmt.Sprintf(
+			"--metrics-path must start with /, got %q", conf.MetricsPath,
+		))
+	}
+	if conf.HealthCheck && slices.Contains(healthCheckPaths, conf.MetricsPath) {
+		return newBadCommandError(fmt.Sprintf(
+			"--metrics-path %q conflicts with the health check endpoint", conf.MetricsPath,
+		))
+	}
+	if userHasSetLocal(cmd, "metrics-path") && !conf.Prometheus {
+		cmd.logger.Infof("Ignoring --metrics-path because --prometheus was not set")
+	}
+
+	if conf.ReadinessMaxConnectionsPct > 100 {
+		return newBadCommandError("--readiness-max-connections-pct must be between 0 and 100")
+	}
+	if userHasSetLocal(cmd, "readiness-max-connections-pct") && conf.MaxConnections == 0 {
+		cmd.logger.Infof("Ignoring --readiness-max-connections-pct because --max-connections was not set")
+	}
+	if conf.FriendlyMaxConnError && conf.MaxConnections == 0 {
+		cmd.logger.Infof("Ignoring --friendly-max-conn-error because --max-connections was not set")
+	}
+	if conf.BindRetries < 0 {
+		return newBadCommandError("--bind-retries must not be negative")
+	}
+	if conf.MaxConnectionsQueue < 0 {
+		return newBadCommandError("--max-connections-queue must not be negative")
+	}
+	if conf.MaxConnectionsQueue > 0 && conf.MaxConnections == 0 {
+		cmd.logger.Infof("Ignoring --max-connections-queue because --max-connections was not set")
+	}
+	if conf.RefuseNewOnSigterm && conf.WaitBeforeClose == 0 {
+		cmd.logger.Infof("Ignoring --refuse-new-on-sigterm because --min-sigterm-delay was not set")
+	}
+
+	if conf.StartupDelay < 0 {
+		return newBadCommandError("--startup-delay must not be negative")
+	}
+
+	if conf.MaxStartupTime < 0 {
+		return newBadCommandError("--max-startup-time must not be negative")
+	}
+
+	if conf.DialTimeout <= 0 {
+		return newBadCommandError("--dial-timeout must be positive")
+	}
+
+	if conf.MaxConnectionLifetime < 0 {
+		return newBadCommandError("--max-connection-lifetime must not be negative")
+	}
+
+	if conf.NewConnectionRate < 0 {
+		return newBadCommandError("--new-connection-rate must not be negative")
+	}
+	if conf.NewConnectionBurst < 1 {
+		return newBadCommandError("--new-connection-burst must be at least 1")
+	}
+
+	if userHasSetLocal(cmd, "readiness-tcp-probe") && !userHasSetLocal(cmd, "health-check") {
+		cmd.logger.Infof("Ignoring --readiness-tcp-probe because --health-check was not set")
+	}
+	if conf.HealthCheckInterval < 0 {
+		return newBadCommandError("--health-check-interval must not be negative")
+	}
+	if userHasSetLocal(cmd, "health-check-interval") && !userHasSetLocal(cmd, "health-check") {
+		cmd.logger.Infof("Ignoring --health-check-interval because --health-check was not set")
+	}
+	if conf.HealthCheckInterval > 0 && conf.ReadinessTCPProbe {
+		cmd.logger.Infof("Ignoring --readiness-tcp-probe because --health-check-interval was set")
+	}
+	if conf.HealthCheckStartupSuccesses < 1 {
+		return newBadCommandError("--health-check-startup-successes must be at least 1")
+	}
+	if conf.HealthCheckStartupTimeout < 0 {
+		return newBadCommandError("--health-check-startup-timeout must not be negative")
+	}
+	if conf.HealthCheckOnStartupOnly && !conf.HealthCheck {
+		cmd.logger.Infof("Ignoring --health-check-on-startup-only because --health-check was not set")
+	}
+	if conf.HealthCheckOnStartupOnly && conf.HealthCheck && conf.Prometheus {
+		// The health check server also serves the Prometheus endpoint.
+		return newBadCommandError("cannot specify --health-check-on-startup-only and --prometheus together")
+	}
+	for _, f := range []string{"health-check-startup-successes", "health-check-startup-timeout"} {
+		if userHasSetLocal(cmd, f) && !conf.HealthCheckOnStartupOnly {
+			cmd.logger.Infof("Ignoring --%v because --health-check-on-startup-only was not set", f)
+		}
+	}
+
 	if !userHasSetLocal(cmd, "telemetry-project") && userHasSetLocal(cmd, "telemetry-prefix") {
 		cmd.logger.Infof("Ignoring --telementry-prefix as --telemetry-project was not set")
 	}
-	if !userHasSetLocal(cmd, "telemetry-project") && userHasSetLocal(cmd, "disable-metrics") {
-		cmd.logger.Infof("Ignoring --disable-metrics as --telemetry-project was not set")
+	exportsTelemetry := userHasSetLocal(cmd, "telemetry-project") || userHasSetLocal(cmd, "otlp-endpoint")
+	if !exportsTelemetry && userHasSetLocal(cmd, "disable-metrics") {
+		cmd.logger.Infof("Ignoring --disable-metrics as --telemetry-project or --otlp-endpoint was not set")
+	}
+	if !exportsTelemetry && userHasSetLocal(cmd, "disable-traces") {
+		cmd.logger.Infof("Ignoring --disable-traces as --telemetry-project or --otlp-endpoint was not set")
+	}
+	if conf.OTLPEndpoint != "" {
+		if conf.TelemetryProject != "" {
+			return newBadCommandError("cannot specify --otlp-endpoint and --telemetry-project together")
+		}
+		if _, _, err := net.SplitHostPort(conf.OTLPEndpoint); err != nil {
+			return newBadCommandError(fmt.Sprintf(
+				"--otlp-endpoint must be a host:port, got %q", conf.OTLPEndpoint,
+			))
+		}
+	}
+	for k := range conf.TelemetryLabels {
+		if !labelKeyRegex.MatchString(k) {
+			return newBadCommandError(fmt.Sprintf("invalid --telemetry-labels key %q", k))
+		}
+		if k == "deployment" && conf.DeploymentLabel != "" {
+			return newBadCommandError(
+				"--telemetry-labels key \"deployment\" conflicts with --deployment-label",
+			)
+		}
 	}
-	if !userHasSetLocal(cmd, "telemetry-project") && userHasSetLocal(cmd, "disable-traces") {
-		cmd.logger.Infof("Ignoring --disable-traces as --telemetry-project was not set")
+	if userHasSetLocal(cmd, "otlp-insecure") && conf.OTLPEndpoint == "" {
+		cmd.logger.Infof("Ignoring --otlp-insecure as --otlp-endpoint was not set")
 	}
 
-	if userHasSetLocal(cmd, "user-agent") {
-		defaultUserAgent += " " + cmd.conf.OtherUserAgents
-		conf.UserAgent = defaultUserAgent
+	if userHasSetLocal(cmd, "user-agent-override") {
+		if strings.TrimSpace(conf.UserAgentOverride) == "" {
+			return newBadCommandError("--user-agent-override must not be empty")
+		}
+		conf.UserAgent = conf.UserAgentOverride
+		if userHasSetLocal(cmd, "user-agent") {
+			conf.UserAgent += " " + conf.OtherUserAgents
+		}
+	} else if userHasSetLocal(cmd, "user-agent") {
+		conf.UserAgent = defaultUserAgent + " " + cmd.conf.OtherUserAgents
+	}
+	if conf.UserAgentSuffix != "" {
+		if !userAgentSuffixRegex.MatchString(conf.UserAgentSuffix) {
+			return newBadCommandError(fmt.Sprintf(
+				"invalid --user-agent-suffix %q: may contain only letters, digits, and . _ / -",
+				conf.UserAgentSuffix,
+			))
+		}
+		conf.UserAgent += " " + conf.UserAgentSuffix
+	}
+
+	for alias, region := range conf.RegionAliases {
+		if !regionNameRegex.MatchString(alias) || !regionNameRegex.MatchString(region) {
+			return newBadCommandError(fmt.Sprintf(
+				"invalid --region-alias %s=%s: alias and region may contain only lowercase letters, digits, and -",
+				alias, region,
+			))
+		}
 	}
 
 	var ics []proxy.InstanceConnConfig
 	for _, a := range args {
 		// split into instance uri and query parameters
 		res := strings.SplitN(a, "?", 2)
+		res[0] = expandRegionAlias(res[0], conf.RegionAliases)
 		_, _, _, _, err := proxy.ParseInstanceURI(res[0])
-		if err != nil {
+		switch {
+		case err != nil && conf.SkipURIValidation:
+			cmd.logger.Infof(
+				"Warning: using instance uri %q without validation because --skip-uri-validation is set",
+				res[0],
+			)
+		case err != nil:
 			return newBadCommandError(fmt.Sprintf("could not parse instance uri: %q", res[0]))
 		}
 		ic := proxy.InstanceConnConfig{Name: res[0]}
@@ -891,22 +1720,8 @@ func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 			p, pok := q["port"]
 			u, uok := q["unix-socket"]
 			up, upok := q["unix-socket-path"]
-
-			if aok && uok {
-				return newBadCommandError("cannot specify both address and unix-socket query params")
-			}
-			if pok && uok {
-				return newBadCommandError("cannot specify both port and unix-socket query params")
-			}
-			if aok && upok {
-				return newBadCommandError("cannot specify both address and unix-socket-path query params")
-			}
-			if pok && upok {
-				return newBadCommandError("cannot specify both port and unix-socket-path query params")
-			}
-			if uok && upok {
-				return newBadCommandError("cannot specify both unix-socket-path and unix-socket query params")
-			}
+			ct, ctok := q["connect-timeout"]
+			tsr, tsrok := q["trace-sample-rate"]
 
 			if aok {
 				if len(a) != 1 {
@@ -918,6 +1733,12 @@ func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 							a[0],
 						))
 				}
+				if f := listenNetworkConflict(conf, net.ParseIP(a[0])); f != "" {
+					return newBadCommandError(
+						fmt.Sprintf("cannot use address query param %q with %v",
+							a[0], f,
+						))
+				}
 				ic.Addr = a[0]
 			}
 
@@ -950,6 +1771,34 @@ func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 
 			}
 
+			if ctok {
+				if len(ct) != 1 {
+					return newBadCommandError(fmt.Sprintf("connect-timeout query param should be only one value: %q", a))
+				}
+				d, err := time.ParseDuration(ct[0])
+				if err != nil || d <= 0 {
+					return newBadCommandError(
+						fmt.Sprintf("connect-timeout query param is not a positive duration: %q",
+							ct[0],
+						))
+				}
+				ic.ConnectTimeout = d
+			}
+
+			if tsrok {
+				if len(tsr) != 1 {
+					return newBadCommandError(fmt.Sprintf("trace-sample-rate query param should be only one value: %q", a))
+				}
+				r, err := strconv.Atoi(tsr[0])
+				if err != nil || r <= 0 {
+					return newBadCommandError(
+						fmt.Sprintf("trace-sample-rate query param is not a positive integer: %q",
+							tsr[0],
+						))
+				}
+				ic.TraceSampleRate = r
+			}
+
 			ic.AutoIAMAuthN, err = parseBoolOpt(q, "auto-iam-authn")
 			if err != nil {
 				return err
@@ -962,6 +1811,15 @@ func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 			if err != nil {
 				return err
 			}
+			ic.PrivateIP, err = parseBoolOpt(q, "private-ip")
+			if err != nil {
+				return err
+			}
+			if err := ic.Validate(); err != nil {
+				return newBadCommandError(
+					fmt.Sprintf("invalid query params for %q: %v", res[0], err),
+				)
+			}
 		}
 		ics = append(ics, ic)
 	}
@@ -1003,6 +1861,292 @@ func parseBoolOpt(q url.Values, name string) (*bool, error) {
 	}
 }
 
+// deploymentLabels returns the labels added to all structured logs and
+// exported metrics, or nil if no deployment label is configured.
+func deploymentLabels(conf *proxy.Config) map[string]string {
+	if conf.DeploymentLabel == "" {
+		return nil
+	}
+	return map[string]string{"deployment": conf.DeploymentLabel}
+}
+
+// labelKeyRegex matches the metric label keys accepted by both Prometheus and
+// Cloud Monitoring.
+var labelKeyRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// userAgentSuffixRegex matches the characters allowed in --user-agent-suffix,
+// which keeps the suffix a single token of the user agent.
+var userAgentSuffixRegex = regexp.MustCompile(`^[a-zA-Z0-9._/-]+$`)
+
+// regionNameRegex matches the characters allowed in the aliases and regions
+// of --region-alias.
+var regionNameRegex = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// expandRegionAlias replaces the region of the instance URI inst with the
+// region it stands for in aliases. Other URIs are returned unchanged.
+func expandRegionAlias(inst string, aliases map[string]string) string {
+	if len(aliases) == 0 {
+		return inst
+	}
+	parts := strings.Split(inst, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if !strings.EqualFold(parts[i], "locations") {
+			continue
+		}
+		if r, ok := aliases[parts[i+1]]; ok {
+			parts[i+1] = r
+		}
+		break
+	}
+	return strings.Join(parts, "/")
+}
+
+// metricLabels returns the labels added to all exported metrics, or nil if
+// none are configured.
+func metricLabels(conf *proxy.Config) map[string]string {
+	labels := deploymentLabels(conf)
+	if len(conf.TelemetryLabels) == 0 {
+		return labels
+	}
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	for k, v := range conf.TelemetryLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// startTelemetry configures the metrics and trace exporters for conf. It
+// returns the handler of the Prometheus endpoint, which is nil unless
+// conf.Prometheus is set, and a function that flushes and stops the
+// exporters. To apply a new telemetry configuration, call the returned
+// function and then call startTelemetry again.
+func startTelemetry(conf *proxy.Config) (http.Handler, func(), error) {
+	enableMetrics := !conf.DisableMetrics
+	enableTraces := !conf.DisableTraces
+	exporting := conf.TelemetryProject != "" || conf.OTLPEndpoint != ""
+	if conf.Prometheus || exporting && enableMetrics {
+		if err := proxy.InitMetrics(); err != nil {
+			return nil, nil, err
+		}
+	}
+	var prom http.Handler
+	if conf.Prometheus {
+		e, err := prometheus.NewExporter(prometheus.Options{
+			Namespace:   conf.PrometheusNamespace,
+			ConstLabels: metricLabels(conf),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		prom = e
+	}
+	stop, err := startExporters(conf, enableMetrics, enableTraces)
+	if err != nil {
+		return nil, nil, err
+	}
+	return prom, stop, nil
+}
+
+// startExporters starts the exporters that push metrics and traces to Cloud
+// Monitoring and Cloud Trace, or to an OTLP endpoint, and returns a function
+// that flushes and stops them.
+func startExporters(conf *proxy.Config, enableMetrics, enableTraces bool) (func(), error) {
+	if conf.OTLPEndpoint != "" {
+		return startOTLP(conf, enableMetrics, enableTraces)
+	}
+	if conf.TelemetryProject == "" || !enableMetrics && !enableTraces {
+		return func() {}, nil
+	}
+	opts := stackdriver.Options{
+		ProjectID:    conf.TelemetryProject,
+		MetricPrefix: conf.TelemetryPrefix,
+	}
+	if labels := metricLabels(conf); labels != nil {
+		// Setting DefaultMonitoringLabels replaces the default task label,
+		// which keeps time series unique to this process. So add it back.
+		hostname, _ := os.Hostname()
+		ml := &stackdriver.Labels{}
+		ml.Set("opencensus_task", fmt.Sprintf("go-%d@%s", os.Getpid(), hostname), "Opencensus task identifier")
+		for k, v := range labels {
+			ml.Set(k, v, "Label of the Proxy")
+		}
+		opts.DefaultMonitoringLabels = ml
+	}
+	sd, err := stackdriver.NewExporter(opts)
+	if err != nil {
+		return nil, err
+	}
+	if enableMetrics {
+		err = sd.StartMetricsExporter()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if enableTraces {
+		s := trace.ProbabilitySampler(1 / float64(conf.TelemetryTracingSampleRate))
+		trace.ApplyConfig(trace.Config{DefaultSampler: followParent(s)})
+		trace.RegisterExporter(sd)
+	}
+	return func() {
+		if enableTraces {
+			trace.UnregisterExporter(sd)
+		}
+		sd.Flush()
+		sd.StopMetricsExporter()
+	}, nil
+}
+
+// followParent returns a sampler that samples a span with a parent only when
+// the parent was sampled and uses root for spans without one. This carries the
+// decision of the span the Proxy starts for an instance with a
+// trace-sample-rate through to the connector's spans.
+func followParent(root trace.Sampler) trace.Sampler {
+	return func(p trace.SamplingParameters) trace.SamplingDecision {
+		if p.ParentContext.TraceID != (trace.TraceID{}) {
+			return trace.SamplingDecision{Sample: p.ParentContext.IsSampled()}
+		}
+		return root(p)
+	}
+}
+
+// otlpShutdownTimeout bounds how long the Proxy spends flushing metrics and
+// traces to the OTLP endpoint on shutdown.
+const otlpShutdownTimeout = 5 * time.Second
+
+// startOTLP bridges the Proxy's OpenCensus metrics and traces to OpenTelemetry
+// and exports them to conf.OTLPEndpoint.
+func startOTLP(conf *proxy.Config, enableMetrics, enableTraces bool) (func(), error) {
+	ctx := context.Background()
+	attrs := []attribute.KeyValue{semconv.ServiceName("alloydb-auth-proxy")}
+	for k, v := range metricLabels(conf) {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	res := resource.NewSchemaless(attrs...)
+
+	var shutdowns []func(context.Context) error
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), otlpShutdownTimeout)
+		defer cancel()
+		for _, s := range shutdowns {
+			_ = s(ctx)
+		}
+	}
+	if enableMetrics {
+		opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(conf.OTLPEndpoint)}
+		if conf.OTLPInsecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+		exp, err := otlpmetricgrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		mp := sdkmetric.NewMeterProvider(
+			sdkmetric.WithResource(res),
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(
+				exp, sdkmetric.WithProducer(ocbridge.NewMetricProducer()),
+			)),
+		)
+		shutdowns = append(shutdowns, mp.Shutdown)
+	}
+	if enableTraces {
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(conf.OTLPEndpoint)}
+		if conf.OTLPInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exp, err := otlptracegrpc.New(ctx, opts...)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		tp := sdktrace.NewTracerProvider(
+			sdktrace.WithResource(res),
+			sdktrace.WithBatcher(exp),
+			sdktrace.WithSampler(sdktrace.ParentBased(
+				sdktrace.TraceIDRatioBased(1/float64(conf.TelemetryTracingSampleRate)),
+			)),
+		)
+		// The bridge replaces the global OpenCensus tracer, so restore the
+		// original when stopping.
+		prev := trace.DefaultTracer
+		ocbridge.InstallTraceBridge(ocbridge.WithTracerProvider(tp))
+		shutdowns = append(shutdowns, func(ctx context.Context) error {
+			trace.DefaultTracer = prev
+			return tp.Shutdown(ctx)
+		})
+	}
+	return stop, nil
+}
+
+// printConfig writes the resolved configuration as JSON to the command's
+// output. Tokens and credentials are redacted.
+func printConfig(cmd *Command) error {
+	defer cmd.cleanup()
+	b, err := json.MarshalIndent(cmd.conf.Redacted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), string(b))
+	return err
+}
+
+// sdNotify sends a notification to systemd. Tests replace it to observe the
+// notifications.
+var sdNotify = daemon.SdNotify
+
+// supportsFUSE reports whether FUSE is supported on the host. Tests replace it
+// to simulate hosts with and without FUSE.
+var supportsFUSE = proxy.SupportsFUSE
+
+// startWatchdog sends keep-alives to the systemd watchdog at half its interval
+// until ctx is done. It does nothing unless systemd configured a watchdog for
+// the proxy.
+func startWatchdog(ctx context.Context, l alloydb.Logger) {
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		l.Errorf("Failed to read the systemd watchdog configuration: %v", err)
+		return
+	}
+	if interval == 0 {
+		return
+	}
+	go runWatchdog(ctx, l, interval/2)
+}
+
+// runWatchdog sends a keep-alive to the systemd watchdog every period until
+// ctx is done.
+func runWatchdog(ctx context.Context, l alloydb.Logger, period time.Duration) {
+	t := time.NewTicker(period)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := sdNotify(false, daemon.SdNotifyWatchdog); err != nil {
+				l.Errorf("Failed to notify the systemd watchdog: %v", err)
+			}
+		}
+	}
+}
+
+// writePIDFile writes the process ID to path.
+func writePIDFile(path string) error {
+	pid := strconv.Itoa(os.Getpid()) + "\n"
+	if err := os.WriteFile(path, []byte(pid), 0644); err != nil {
+		return fmt.Errorf("failed to write PID file: %v", err)
+	}
+	return nil
+}
+
+// removePIDFile removes the PID file written by writePIDFile.
+func removePIDFile(l alloydb.Logger, path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		l.Errorf("Failed to remove PID file: %v", err)
+	}
+}
+
 // runSignalWrapper watches for SIGTERM and SIGINT and interupts execution if necessary.
 func runSignalWrapper(cmd *Command) (err error) {
 	defer cmd.cleanup()
@@ -1011,32 +2155,15 @@ func runSignalWrapper(cmd *Command) (err error) {
 
 	// Configure collectors before the proxy has started to ensure we are
 	// collecting metrics before *ANY* AlloyDB Admin API calls are made.
-	enableMetrics := !cmd.conf.DisableMetrics
-	enableTraces := !cmd.conf.DisableTraces
-	if cmd.conf.TelemetryProject != "" && (enableMetrics || enableTraces) {
-		sd, err := stackdriver.NewExporter(stackdriver.Options{
-			ProjectID:    cmd.conf.TelemetryProject,
-			MetricPrefix: cmd.conf.TelemetryPrefix,
-		})
-		if err != nil {
-			return err
-		}
-		if enableMetrics {
-			err = sd.StartMetricsExporter()
-			if err != nil {
-				return err
-			}
-		}
-		if enableTraces {
-			s := trace.ProbabilitySampler(1 / float64(cmd.conf.TelemetryTracingSampleRate))
-			trace.ApplyConfig(trace.Config{DefaultSampler: s})
-			trace.RegisterExporter(sd)
-		}
-		defer func() {
-			sd.Flush()
-			sd.StopMetricsExporter()
-		}()
+	promHandler, stopTelemetry, err := startTelemetry(cmd.conf)
+	if err != nil {
+		return err
 	}
+	defer stopTelemetry()
+
+	// If running under systemd with WatchdogSec, keep the watchdog from
+	// restarting the proxy for as long as it runs.
+	startWatchdog(ctx, cmd.logger)
 
 	shutdownCh := make(chan error)
 	// watch for sigterm / sigint signals
@@ -1047,7 +2174,8 @@ func runSignalWrapper(cmd *Command) (err error) {
 		select {
 		case s = <-signals:
 		case <-ctx.Done():
-			// this should only happen when the context supplied in tests in canceled
+			// The Command's context was canceled, e.g., by an embedder
+			// using WithContext, or in tests.
 			s = syscall.SIGINT
 		}
 		switch s {
@@ -1062,36 +2190,73 @@ func runSignalWrapper(cmd *Command) (err error) {
 		}
 	}()
 
+	// A run not started with RunWithResult records into a result that is
+	// discarded.
+	result := cmd.result
+	if result == nil {
+		result = &RunResult{}
+	}
+	result.Instances = len(cmd.conf.Instances)
+
 	// Start the proxy asynchronously, so we can exit early if a shutdown signal is sent
 	startCh := make(chan *proxy.Client)
 	go func() {
 		defer close(startCh)
 		p, err := proxy.NewClient(ctx, cmd.dialer, cmd.logger, cmd.conf)
 		if err != nil {
-			shutdownCh <- fmt.Errorf("unable to start: %v", err)
+			select {
+			case shutdownCh <- fmt.Errorf("unable to start: %v", err):
+			case <-ctx.Done():
+				// A shutdown signal interrupted startup.
+			}
 			return
 		}
-		startCh <- p
+		select {
+		case startCh <- p:
+		case <-ctx.Done():
+			// Startup took too long or a shutdown signal arrived, so
+			// nothing is waiting for the Client.
+			_ = p.Close()
+		}
 	}()
+	var startupTimeout <-chan time.Time
+	if cmd.conf.MaxStartupTime > 0 {
+		t := time.NewTimer(cmd.conf.MaxStartupTime)
+		defer t.Stop()
+		startupTimeout = t.C
+	}
 	// Wait for either startup to finish or a signal to interupt
 	var p *proxy.Client
 	select {
 	case err := <-shutdownCh:
 		cmd.logger.Errorf("The proxy has encountered a terminal error: %v", err)
-		// If running under systemd with Type=notify, it will send a message to the
-		// service manager that a failure occurred and it is terminating.
-		go func() {
-			if _, err := daemon.SdNotify(false, daemon.SdNotifyStopping); err != nil {
-				cmd.logger.Errorf("Failed to notify systemd of termination: %v", err)
-			}
-		}()
+		notifyStopping(cmd.logger)
+		result.StartupErr = err
+		return err
+	case <-startupTimeout:
+		err := fmt.Errorf("unable to start: startup did not complete within --max-startup-time (%v)",
+			cmd.conf.MaxStartupTime)
+		cmd.logger.Errorf("The proxy has encountered a terminal error: %v", err)
+		notifyStopping(cmd.logger)
+		result.StartupErr = err
 		return err
 	case p = <-startCh:
+		if cmd.conf.PIDFile != "" {
+			if err := writePIDFile(cmd.conf.PIDFile); err != nil {
+				_ = p.Close()
+				result.StartupErr = err
+				return err
+			}
+			defer removePIDFile(cmd.logger, cmd.conf.PIDFile)
+		}
+		for _, a := range p.Addrs() {
+			result.Addrs = append(result.Addrs, a.String())
+		}
 		cmd.logger.Infof("The proxy has started successfully and is ready for new connections!")
 		// If running under systemd with Type=notify, it will send a message to the
 		// service manager that it is ready to handle connections now.
 		go func() {
-			if _, err := daemon.SdNotify(false, daemon.SdNotifyReady); err != nil {
+			if _, err := sdNotify(false, daemon.SdNotifyReady); err != nil {
 				cmd.logger.Errorf("Failed to notify systemd of readiness: %v", err)
 			}
 		}()
@@ -1113,23 +2278,38 @@ func runSignalWrapper(cmd *Command) (err error) {
 
 	if cmd.conf.Prometheus {
 		needsHTTPServer = true
-		e, err := prometheus.NewExporter(prometheus.Options{
-			Namespace: cmd.conf.PrometheusNamespace,
-		})
-		if err != nil {
-			return err
-		}
-		mux.Handle("/metrics", e)
+		mux.Handle(cmd.conf.MetricsPath, promHandler)
 	}
 
+	// logServer logs the startup of the health check and admin servers,
+	// unless --quiet-healthchecks is set.
+	logServer := func(format string, args ...interface{}) {
+		if !cmd.conf.QuietHealthChecks {
+			cmd.logger.Infof(format, args...)
+		}
+	}
+	// httpCtx controls the lifetime of the HTTP server, which
+	// --health-check-on-startup-only may stop before the Proxy exits.
+	httpCtx, stopHTTPServer := context.WithCancel(ctx)
+	defer stopHTTPServer()
 	if cmd.conf.HealthCheck {
 		needsHTTPServer = true
-		cmd.logger.Infof("Starting health check server at %s",
+		logServer("Starting health check server at %s",
 			net.JoinHostPort(cmd.conf.HTTPAddress, cmd.conf.HTTPPort))
 		hc := healthcheck.NewCheck(p, cmd.logger)
+		if cmd.conf.HealthCheckOnStartupOnly {
+			go stopAfterStartup(httpCtx, cmd.logger, hc.NotifyAfterStartup(cmd.conf.HealthCheckStartupSuccesses),
+				cmd.conf.HealthCheckStartupTimeout, stopHTTPServer)
+		}
+		// Keep healthCheckPaths in sync with these routes.
 		mux.HandleFunc("/startup", hc.HandleStartup)
 		mux.HandleFunc("/readiness", hc.HandleReadiness)
 		mux.HandleFunc("/liveness", hc.HandleLiveness)
+		mux.HandleFunc("/instances/health", hc.HandleInstancesHealth)
+		mux.HandleFunc("/connector-health", hc.HandleConnectorHealth)
+		if cmd.conf.HealthCheckInterval > 0 {
+			hc.StartChecks(httpCtx, cmd.conf.HealthCheckInterval)
+		}
 		notifyStarted = hc.NotifyStarted
 		notifyStopped = hc.NotifyStopped
 	}
@@ -1137,10 +2317,13 @@ func runSignalWrapper(cmd *Command) (err error) {
 	// Start the HTTP server if anything requiring HTTP is specified.
 	if needsHTTPServer {
 		go startHTTPServer(
-			ctx,
+			httpCtx,
 			cmd.logger,
 			net.JoinHostPort(cmd.conf.HTTPAddress, cmd.conf.HTTPPort),
 			mux,
+			cmd.conf.HTTPReadHeaderTimeout,
+			httpWriteTimeout,
+			cmd.conf.HTTPShutdownTimeout,
 			shutdownCh,
 		)
 	}
@@ -1148,17 +2331,29 @@ func runSignalWrapper(cmd *Command) (err error) {
 	var (
 		needsAdminServer bool
 		m                = http.NewServeMux()
+		// shuttingDown is set once the Proxy begins to shut down.
+		shuttingDown atomic.Bool
 	)
 	if cmd.conf.QuitQuitQuit {
 		needsAdminServer = true
-		cmd.logger.Infof("Enabling quitquitquit endpoint at localhost:%v", cmd.conf.AdminPort)
+		logServer("Enabling quitquitquit endpoint at localhost:%v", cmd.conf.AdminPort)
 		// quitquitquit allows for shutdown on localhost only.
-		var quitOnce sync.Once
-		m.HandleFunc("/quitquitquit", quitquitquit(&quitOnce, shutdownCh))
+		m.HandleFunc("/quitquitquit", quitquitquit(&shuttingDown, shutdownCh))
+	}
+	if cmd.conf.ManualStart {
+		needsAdminServer = true
+		logServer("Enabling start and stop endpoints at localhost:%v", cmd.conf.AdminPort)
+		m.HandleFunc("/start", postOnly(p.Start))
+		m.HandleFunc("/stop", postOnly(p.Stop))
+	}
+	if cmd.conf.ReloadCredentials {
+		needsAdminServer = true
+		logServer("Enabling reload-credentials endpoint at localhost:%v", cmd.conf.AdminPort)
+		m.HandleFunc("/reload-credentials", reloadCredentials(cmd.logger, p))
 	}
 	if cmd.conf.Debug {
 		needsAdminServer = true
-		cmd.logger.Infof("Enabling pprof endpoints at localhost:%v", cmd.conf.AdminPort)
+		logServer("Enabling pprof endpoints at localhost:%v", cmd.conf.AdminPort)
 		// pprof standard endpoints
 		m.HandleFunc("/debug/pprof/", pprof.Index)
 		m.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
@@ -1172,6 +2367,9 @@ func runSignalWrapper(cmd *Command) (err error) {
 			cmd.logger,
 			net.JoinHostPort("localhost", cmd.conf.AdminPort),
 			m,
+			cmd.conf.HTTPReadHeaderTimeout,
+			0, // no write timeout, see httpWriteTimeout
+			cmd.conf.HTTPShutdownTimeout,
 			shutdownCh,
 		)
 	}
@@ -1179,12 +2377,19 @@ func runSignalWrapper(cmd *Command) (err error) {
 	go func() { shutdownCh <- p.Serve(ctx, notifyStarted) }()
 
 	err = <-shutdownCh
+	shuttingDown.Store(true)
 	switch {
 	case errors.Is(err, errSigInt):
 		cmd.logger.Infof("SIGINT signal received. Shutting down...")
-		time.Sleep(cmd.conf.WaitBeforeClose)
-	case errors.Is(err, errSigTerm):
+		time.Sleep(cmd.conf.WaitBeforeCloseOnSigint)
+	case errors.Is(err, errSigTerm), errors.Is(err, errSigTermZero):
 		cmd.logger.Infof("SIGTERM signal received. Shutting down...")
+		if cmd.conf.RefuseNewOnSigterm {
+			cmd.logger.Infof("Refusing new connections")
+			if cErr := p.CloseListeners(); cErr != nil {
+				cmd.logger.Errorf("Failed to close listeners: %v", cErr)
+			}
+		}
 		time.Sleep(cmd.conf.WaitBeforeClose)
 	default:
 		cmd.logger.Errorf("The proxy has encountered a terminal error: %v", err)
@@ -1192,27 +2397,110 @@ func runSignalWrapper(cmd *Command) (err error) {
 	return err
 }
 
-func quitquitquit(quitOnce *sync.Once, shutdownCh chan<- error) http.HandlerFunc {
+// notifyStopping tells systemd, when running with Type=notify, that the Proxy
+// failed and is terminating.
+func notifyStopping(l alloydb.Logger) {
+	go func() {
+		if _, err := sdNotify(false, daemon.SdNotifyStopping); err != nil {
+			l.Errorf("Failed to notify systemd of termination: %v", err)
+		}
+	}()
+}
+
+// quitquitquit returns a handler that shuts down the Proxy. Requests after
+// shutdown has begun receive a 503.
+func quitquitquit(shuttingDown *atomic.Bool, shutdownCh chan<- error) http.HandlerFunc {
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		if req.Method != http.MethodPost && req.Method != http.MethodGet {
 			rw.WriteHeader(400)
 			return
 		}
-		quitOnce.Do(func() {
-			select {
-			case shutdownCh <- errQuitQuitQuit:
-			default:
-				// The write attempt to shutdownCh failed and
-				// the proxy is already exiting.
-			}
-		})
+		// Only the first request triggers shutdown. Any request after
+		// shutdown has begun, for whatever reason, is told so.
+		if !shuttingDown.CompareAndSwap(false, true) {
+			http.Error(rw, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		select {
+		case shutdownCh <- errQuitQuitQuit:
+		default:
+			// The write attempt to shutdownCh failed and
+			// the proxy is already exiting.
+		}
+	})
+}
+
+// postOnly returns a handler that calls f for POST requests and rejects all
+// other methods.
+func postOnly(f func()) http.HandlerFunc {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			rw.WriteHeader(400)
+			return
+		}
+		f()
+	})
+}
+
+// reloadCredentials returns a handler that reloads the Proxy's credentials on
+// POST requests and reports any failure to the caller.
+func reloadCredentials(l alloydb.Logger, p *proxy.Client) http.HandlerFunc {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			rw.WriteHeader(400)
+			return
+		}
+		if err := p.ReloadCredentials(); err != nil {
+			l.Errorf("%v", err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+		}
 	})
 }
 
-func startHTTPServer(ctx context.Context, l alloydb.Logger, addr string, mux *http.ServeMux, shutdownCh chan<- error) {
+// stopAfterStartup calls stop once started is closed or, when timeout is
+// positive, once timeout has elapsed, whichever comes first.
+func stopAfterStartup(ctx context.Context, l alloydb.Logger, started <-chan struct{}, timeout time.Duration, stop func()) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	select {
+	case <-ctx.Done():
+		return
+	case <-started:
+		l.Infof("Stopping health check server after successful startup")
+	case <-expired:
+		l.Infof("Stopping health check server after --health-check-startup-timeout (%v)", timeout)
+	}
+	stop()
+}
+
+// healthCheckPaths are the routes served by the health check, which the
+// Prometheus endpoint may not share.
+var healthCheckPaths = []string{
+	"/startup", "/readiness", "/liveness", "/instances/health", "/connector-health",
+}
+
+const (
+	// httpReadTimeout is the maximum duration for reading an entire request
+	// to the HTTP and admin servers.
+	httpReadTimeout = 30 * time.Second
+	// httpWriteTimeout is the maximum duration for writing a response from
+	// the HTTP server. The admin server has no write timeout because pprof
+	// profiles and traces may run for any requested duration, and some Go
+	// releases refuse ones that exceed the server's WriteTimeout.
+	httpWriteTimeout = 60 * time.Second
+)
+
+func startHTTPServer(ctx context.Context, l alloydb.Logger, addr string, mux *http.ServeMux, readHeaderTimeout, writeTimeout, shutdownTimeout time.Duration, shutdownCh chan<- error) {
 	server := &http.Server{
-		Addr:    addr,
-		Handler: mux,
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       httpReadTimeout,
+		WriteTimeout:      writeTimeout,
 	}
 	// Start the HTTP server.
 	go func() {
@@ -1226,8 +2514,8 @@ func startHTTPServer(ctx context.Context, l alloydb.Logger, addr string, mux *ht
 	}()
 	// Handle shutdown of the HTTP server gracefully.
 	<-ctx.Done()
-	// Give the HTTP server a second to shutdown cleanly.
-	ctx2, cancel := context.WithTimeout(context.Background(), time.Second)
+	// Give in-flight requests time to finish.
+	ctx2, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 	if err := server.Shutdown(ctx2); err != nil {
 		l.Errorf("failed to shutdown HTTP server: %v\n", err)
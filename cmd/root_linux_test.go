@@ -16,9 +16,12 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -33,6 +36,8 @@ func TestNewCommandArgumentsOnLinux(t *testing.T) {
 		args        []string
 		wantDir     string
 		wantTempDir string
+		wantSuffix  string
+		wantNoAuto  bool
 	}{
 		{
 			desc:        "using the fuse flag",
@@ -46,6 +51,20 @@ func TestNewCommandArgumentsOnLinux(t *testing.T) {
 			wantDir:     "/alloydb",
 			wantTempDir: "/mycooldir",
 		},
+		{
+			desc:        "using the fuse socket suffix flag",
+			args:        []string{"--fuse", "/alloydb", "--fuse-socket-suffix", "6432"},
+			wantDir:     "/alloydb",
+			wantTempDir: defaultTmp,
+			wantSuffix:  "6432",
+		},
+		{
+			desc:        "using the fuse no autoconnect flag",
+			args:        []string{"--fuse", "/alloydb", "--fuse-no-autoconnect"},
+			wantDir:     "/alloydb",
+			wantTempDir: defaultTmp,
+			wantNoAuto:  true,
+		},
 	}
 
 	for _, tc := range tcs {
@@ -72,10 +91,40 @@ func TestNewCommandArgumentsOnLinux(t *testing.T) {
 			if got, want := c.conf.FUSETempDir, tc.wantTempDir; got != want {
 				t.Fatalf("FUSEDir: want = %v, got = %v", want, got)
 			}
+
+			if got, want := c.conf.FUSESocketSuffix, tc.wantSuffix; got != want {
+				t.Fatalf("FUSESocketSuffix: want = %v, got = %v", want, got)
+			}
+
+			if got, want := c.conf.FUSENoAutoconnect, tc.wantNoAuto; got != want {
+				t.Fatalf("FUSENoAutoconnect: want = %v, got = %v", want, got)
+			}
 		})
 	}
 }
 
+func TestNewCommandWithInvalidFUSESocketSuffix(t *testing.T) {
+	for _, suffix := range []string{"abc", "0", "65536", "06432"} {
+		t.Run(suffix, func(t *testing.T) {
+			_, err := invokeProxyCommand([]string{
+				"--fuse", "/alloydb", "--fuse-socket-suffix", suffix,
+			})
+			if err == nil || !strings.Contains(err.Error(), "--fuse-socket-suffix") {
+				t.Fatalf("want --fuse-socket-suffix error, got = %v", err)
+			}
+		})
+	}
+}
+
+func TestNewCommandWithNamedPipeOnLinux(t *testing.T) {
+	_, err := invokeProxyCommand([]string{
+		"--named-pipe", "projects/proj/locations/region/clusters/clust/instances/inst",
+	})
+	if err == nil || !strings.Contains(err.Error(), "--named-pipe is not supported") {
+		t.Fatalf("want --named-pipe error, got = %v", err)
+	}
+}
+
 func TestSdNotifyOnLinux(t *testing.T) {
 	tcs := []struct {
 		desc          string
@@ -144,3 +193,140 @@ func TestSdNotifyOnLinux(t *testing.T) {
 		})
 	}
 }
+
+func TestSignalDelays(t *testing.T) {
+	tcs := []struct {
+		desc      string
+		signal    syscall.Signal
+		httpPort  string
+		args      []string
+		wantDelay time.Duration
+		wantErr   error
+	}{
+		{
+			desc:     "SIGTERM waits for min-sigterm-delay",
+			signal:   syscall.SIGTERM,
+			httpPort: "9096",
+			args: []string{"--health-check", "--http-port", "9096",
+				"--min-sigterm-delay", "500ms", "--min-sigint-delay", "10s",
+				"projects/proj/locations/region/clusters/clust/instances/inst?port=5326"},
+			wantDelay: 500 * time.Millisecond,
+			wantErr:   errSigTerm,
+		},
+		{
+			desc:     "SIGTERM with exit-zero-sigterm waits for min-sigterm-delay",
+			signal:   syscall.SIGTERM,
+			httpPort: "9202",
+			args: []string{"--health-check", "--http-port", "9202", "--exit-zero-sigterm",
+				"--min-sigterm-delay", "500ms", "--min-sigint-delay", "10s",
+				"projects/proj/locations/region/clusters/clust/instances/inst?port=5351"},
+			wantDelay: 500 * time.Millisecond,
+			wantErr:   errSigTermZero,
+		},
+		{
+			desc:     "SIGINT waits for min-sigint-delay",
+			signal:   syscall.SIGINT,
+			httpPort: "9097",
+			args: []string{"--health-check", "--http-port", "9097",
+				"--min-sigterm-delay", "10s", "--min-sigint-delay", "500ms",
+				"projects/proj/locations/region/clusters/clust/instances/inst?port=5327"},
+			wantDelay: 500 * time.Millisecond,
+			wantErr:   errSigInt,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := NewCommand(WithDialer(&spyDialer{}))
+			c.SilenceUsage = true
+			c.SilenceErrors = true
+			c.SetArgs(tc.args)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			errCh := make(chan error)
+			go func() {
+				errCh <- c.ExecuteContext(ctx)
+			}()
+			// The HTTP server starts after the signal handler is registered.
+			if _, err := tryDial("GET", "http://localhost:"+tc.httpPort+"/liveness"); err != nil {
+				t.Fatalf("failed to dial liveness endpoint: %v", err)
+			}
+
+			start := time.Now()
+			if err := syscall.Kill(syscall.Getpid(), tc.signal); err != nil {
+				t.Fatalf("syscall.Kill error: %v", err)
+			}
+			select {
+			case err := <-errCh:
+				if err != tc.wantErr {
+					t.Fatalf("want = %v, got = %v", tc.wantErr, err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for the proxy to shut down")
+			}
+			if got := time.Since(start); got < tc.wantDelay {
+				t.Fatalf("want delay of at least %v, got = %v", tc.wantDelay, got)
+			}
+		})
+	}
+}
+
+func TestRefuseNewOnSigterm(t *testing.T) {
+	c := NewCommand(WithDialer(&pipeDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--refuse-new-on-sigterm",
+		"--min-sigterm-delay", "1s", "--max-sigterm-delay", "2s",
+		"--health-check", "--http-port", "9196",
+		"projects/proj/locations/region/clusters/clust/instances/inst?port=5335"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- c.ExecuteContext(ctx)
+	}()
+	// The HTTP server starts after the signal handler is registered.
+	if _, err := tryDial("GET", "http://localhost:9196/liveness"); err != nil {
+		t.Fatalf("failed to dial liveness endpoint: %v", err)
+	}
+	conn, err := net.Dial("tcp", "127.0.0.1:5335")
+	if err != nil {
+		t.Fatalf("net.Dial error: %v", err)
+	}
+	defer conn.Close()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("syscall.Kill error: %v", err)
+	}
+	// New connections are refused during --min-sigterm-delay.
+	refused := false
+	for i := 0; i < 10; i++ {
+		newConn, err := net.Dial("tcp", "127.0.0.1:5335")
+		if err != nil {
+			refused = true
+			break
+		}
+		newConn.Close()
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !refused {
+		t.Fatal("want new connections refused after SIGTERM")
+	}
+	// The open connection persists.
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	var netErr net.Error
+	if _, err := conn.Read(make([]byte, 1)); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("want open connection to persist, got read error = %v", err)
+	}
+	conn.Close()
+
+	select {
+	case err := <-errCh:
+		if err != errSigTerm {
+			t.Fatalf("want = %v, got = %v", errSigTerm, err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for the proxy to shut down")
+	}
+}
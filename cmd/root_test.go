@@ -15,23 +15,38 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/alloydbconn"
+	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/internal/log"
 	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/internal/proxy"
+	"github.com/coreos/go-systemd/v22/daemon"
 	"github.com/google/go-cmp/cmp"
 	"github.com/spf13/cobra"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -78,6 +93,9 @@ func withDefaults(c *proxy.Config) *proxy.Config {
 	if c.Port == 0 {
 		c.Port = 5432
 	}
+	if c.ListenNetwork == "" {
+		c.ListenNetwork = "tcp"
+	}
 	if c.FUSEDir == "" {
 		if c.Instances == nil {
 			c.Instances = []proxy.InstanceConnConfig{{}}
@@ -95,9 +113,27 @@ func withDefaults(c *proxy.Config) *proxy.Config {
 	if c.HTTPPort == "" {
 		c.HTTPPort = "9090"
 	}
+	if c.MetricsPath == "" {
+		c.MetricsPath = "/metrics"
+	}
 	if c.AdminPort == "" {
 		c.AdminPort = "9091"
 	}
+	if c.NewConnectionBurst == 0 {
+		c.NewConnectionBurst = 1
+	}
+	if c.HealthCheckStartupSuccesses == 0 {
+		c.HealthCheckStartupSuccesses = 1
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = 30 * time.Second
+	}
+	if c.HTTPReadHeaderTimeout == 0 {
+		c.HTTPReadHeaderTimeout = 10 * time.Second
+	}
+	if c.HTTPShutdownTimeout == 0 {
+		c.HTTPShutdownTimeout = time.Second
+	}
 	if c.TelemetryTracingSampleRate == 0 {
 		c.TelemetryTracingSampleRate = 10_000
 	}
@@ -144,6 +180,91 @@ func TestUserAgent(t *testing.T) {
 	}
 }
 
+func TestUserAgentOverride(t *testing.T) {
+	tcs := []struct {
+		desc string
+		args []string
+		want string
+	}{
+		{
+			desc: "override replaces the default",
+			args: []string{"--user-agent-override", "custom-agent/1.0.0"},
+			want: "custom-agent/1.0.0",
+		},
+		{
+			desc: "user agents are appended to the override",
+			args: []string{
+				"--user-agent-override", "custom-agent/1.0.0",
+				"--user-agent", "some-runtime/0.0.1",
+			},
+			want: "custom-agent/1.0.0 some-runtime/0.0.1",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			cmd, err := invokeProxyCommand(append(tc.args,
+				"projects/proj/locations/region/clusters/clust/instances/inst",
+			))
+			if err != nil {
+				t.Fatalf("want error = nil, got = %v", err)
+			}
+			if got := cmd.conf.UserAgent; got != tc.want {
+				t.Errorf("want user agent = %q, got = %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUserAgentAppendsToDefault(t *testing.T) {
+	cmd, err := invokeProxyCommand([]string{
+		"--user-agent", "some-runtime/0.0.1",
+		"projects/proj/locations/region/clusters/clust/instances/inst",
+	})
+	if err != nil {
+		t.Fatalf("want error = nil, got = %v", err)
+	}
+	want := "alloy-db-auth-proxy/" + versionString
+	if got := cmd.conf.UserAgent; !strings.HasPrefix(got, want) || !strings.HasSuffix(got, " some-runtime/0.0.1") {
+		t.Errorf("want user agent to start with %q and end with the appended agent, got = %q", want, got)
+	}
+}
+
+func TestUserAgentSuffix(t *testing.T) {
+	tcs := []struct {
+		desc string
+		args []string
+		want string
+	}{
+		{
+			desc: "suffix is appended to the default",
+			args: []string{"--user-agent-suffix", "deployment/prod-east"},
+			want: "alloy-db-auth-proxy/" + versionString + " deployment/prod-east",
+		},
+		{
+			desc: "suffix follows the override and user agents",
+			args: []string{
+				"--user-agent-override", "custom-agent/1.0.0",
+				"--user-agent", "some-runtime/0.0.1",
+				"--user-agent-suffix", "team_a.v2",
+			},
+			want: "custom-agent/1.0.0 some-runtime/0.0.1 team_a.v2",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			cmd, err := invokeProxyCommand(append(tc.args,
+				"projects/proj/locations/region/clusters/clust/instances/inst",
+			))
+			if err != nil {
+				t.Fatalf("want error = nil, got = %v", err)
+			}
+			if got := cmd.conf.UserAgent; got != tc.want {
+				t.Errorf("want user agent = %q, got = %q", tc.want, got)
+			}
+		})
+	}
+}
+
 func TestNewCommandArguments(t *testing.T) {
 	tcs := []struct {
 		desc string
@@ -295,6 +416,75 @@ func TestNewCommandArguments(t *testing.T) {
 				}},
 			}),
 		},
+		{
+			desc: "using the dial-timeout flag and connect-timeout query param",
+			args: []string{"--dial-timeout", "10s",
+				"projects/proj/locations/region/clusters/clust/instances/inst1?connect-timeout=2s",
+				"projects/proj/locations/region/clusters/clust/instances/inst2",
+			},
+			want: withDefaults(&proxy.Config{
+				DialTimeout: 10 * time.Second,
+				Instances: []proxy.InstanceConnConfig{
+					{
+						Name:           "projects/proj/locations/region/clusters/clust/instances/inst1",
+						ConnectTimeout: 2 * time.Second,
+					},
+					{Name: "projects/proj/locations/region/clusters/clust/instances/inst2"},
+				},
+			}),
+		},
+		{
+			desc: "using the trace-sample-rate query param",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?trace-sample-rate=100"},
+			want: withDefaults(&proxy.Config{
+				Instances: []proxy.InstanceConnConfig{{
+					Name:            "projects/proj/locations/region/clusters/clust/instances/inst",
+					TraceSampleRate: 100,
+				}},
+			}),
+		},
+		{
+			desc: "using the skip-uri-validation flag",
+			args: []string{"--skip-uri-validation", "projects/proj/locations/region/clusters/clust/nodes/node"},
+			want: withDefaults(&proxy.Config{
+				SkipURIValidation: true,
+				Instances: []proxy.InstanceConnConfig{
+					{Name: "projects/proj/locations/region/clusters/clust/nodes/node"},
+				},
+			}),
+		},
+		{
+			desc: "using the skip-uri-validation flag with query params",
+			args: []string{"--skip-uri-validation", "projects/proj/locations/region/clusters/clust/nodes/node?port=6000"},
+			want: withDefaults(&proxy.Config{
+				SkipURIValidation: true,
+				Instances: []proxy.InstanceConnConfig{
+					{Name: "projects/proj/locations/region/clusters/clust/nodes/node", Port: 6000},
+				},
+			}),
+		},
+		{
+			desc: "private IP",
+			args: []string{"--private-ip", "projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				PrivateIP: true,
+				Instances: []proxy.InstanceConnConfig{{Name: "projects/proj/locations/region/clusters/clust/instances/inst"}},
+			}),
+		},
+		{
+			desc: "private IP query param overriding global public IP",
+			args: []string{
+				"--public-ip",
+				"projects/proj/locations/region/clusters/clust/instances/inst1?private-ip=true",
+			},
+			want: withDefaults(&proxy.Config{
+				PublicIP: true,
+				Instances: []proxy.InstanceConnConfig{{
+					PrivateIP: pointer(true),
+					Name:      "projects/proj/locations/region/clusters/clust/instances/inst1",
+				}},
+			}),
+		},
 		{
 			desc: "using the address flag",
 			args: []string{"--address", "0.0.0.0", "projects/proj/locations/region/clusters/clust/instances/inst"},
@@ -366,6 +556,15 @@ func TestNewCommandArguments(t *testing.T) {
 				CredentialsFile: "/path/to/file",
 			}),
 		},
+		{
+			desc: "using the reload-credentials flag",
+			args: []string{"--credentials-file", "/path/to/file", "--reload-credentials",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				CredentialsFile:   "/path/to/file",
+				ReloadCredentials: true,
+			}),
+		},
 		{
 			desc: "using the (short) credentiale file flag",
 			args: []string{"-c", "/path/to/file", "projects/proj/locations/region/clusters/clust/instances/inst"},
@@ -380,6 +579,15 @@ func TestNewCommandArguments(t *testing.T) {
 				UnixSocket: "/path/to/dir/",
 			}),
 		},
+		{
+			desc: "using the disable-instance-uri-lowercasing flag",
+			args: []string{"--unix-socket", "/path/to/dir/", "--disable-instance-uri-lowercasing",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				UnixSocket:                    "/path/to/dir/",
+				DisableInstanceURILowercasing: true,
+			}),
+		},
 		{
 			desc: "using the (short) unix socket flag",
 			args: []string{"-u", "/path/to/dir/", "projects/proj/locations/region/clusters/clust/instances/inst"},
@@ -419,6 +627,13 @@ func TestNewCommandArguments(t *testing.T) {
 				WaitBeforeClose: 10 * time.Second,
 			}),
 		},
+		{
+			desc: "using min-sigint-delay flag",
+			args: []string{"--min-sigint-delay", "10s", "projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				WaitBeforeCloseOnSigint: 10 * time.Second,
+			}),
+		},
 		{
 			desc: "using wait after signterm flag",
 			args: []string{"--max-sigterm-delay", "10s", "projects/proj/locations/region/clusters/clust/instances/inst"},
@@ -433,6 +648,62 @@ func TestNewCommandArguments(t *testing.T) {
 				StructuredLogs: true,
 			}),
 		},
+		{
+			desc: "using the append-application-name flag with a space-separated value",
+			args: []string{"--append-application-name", "alloydb-auth-proxy", "projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				AppendApplicationName: "alloydb-auth-proxy",
+			}),
+		},
+		{
+			desc: "using the append-application-name flag with a value",
+			args: []string{"--append-application-name=billing-proxy", "projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				AppendApplicationName: "billing-proxy",
+			}),
+		},
+		{
+			desc: "using the health-check-on-startup-only flag",
+			args: []string{"--health-check", "--health-check-on-startup-only",
+				"--health-check-startup-successes", "3", "--health-check-startup-timeout", "1m",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				HealthCheck:                 true,
+				HealthCheckOnStartupOnly:    true,
+				HealthCheckStartupSuccesses: 3,
+				HealthCheckStartupTimeout:   time.Minute,
+			}),
+		},
+		{
+			desc: "using the log-format flag",
+			args: []string{"--log-format", "logfmt", "projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				LogFormat: "logfmt",
+			}),
+		},
+		{
+			desc: "using the log-format flag with json and structured logs",
+			args: []string{"--log-format", "json", "--structured-logs",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				LogFormat:      "json",
+				StructuredLogs: true,
+			}),
+		},
+		{
+			desc: "using the log-timestamp-format flag",
+			args: []string{"--log-timestamp-format", "rfc3339nano", "projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				LogTimestampFormat: "rfc3339nano",
+			}),
+		},
+		{
+			desc: "using the log-source flag",
+			args: []string{"--log-source", "projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				LogSource: true,
+			}),
+		},
 		{
 			desc: "using the alloydbadmin-api-endpoint flag with the trailing slash",
 			args: []string{"--alloydbadmin-api-endpoint", "https://test.googleapis.com/", "projects/proj/locations/region/clusters/clust/instances/inst"},
@@ -502,177 +773,435 @@ func TestNewCommandArguments(t *testing.T) {
 			}),
 		},
 		{
-			desc: "using the run-connection-test flag",
-			args: []string{"--run-connection-test",
+			desc: "using the client TLS flags",
+			args: []string{
+				"--client-tls-cert", "/path/to/cert.pem",
+				"--client-tls-key", "/path/to/key.pem",
 				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				RunConnectionTest: true,
+				ClientTLSCert: "/path/to/cert.pem",
+				ClientTLSKey:  "/path/to/key.pem",
 			}),
 		},
 		{
-			desc: "using the debug logs flag",
-			args: []string{"--debug-logs",
+			desc: "using the client TLS client CA flag",
+			args: []string{
+				"--client-tls-cert", "/path/to/cert.pem",
+				"--client-tls-key", "/path/to/key.pem",
+				"--client-tls-client-ca", "/path/to/ca.pem",
 				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				DebugLogs: true,
+				ClientTLSCert:     "/path/to/cert.pem",
+				ClientTLSKey:      "/path/to/key.pem",
+				ClientTLSClientCA: "/path/to/ca.pem",
 			}),
 		},
 		{
-			desc: "using the static connection info flag",
-			args: []string{
-				"--static-connection-info",
-				"myfile.json",
-				"projects/proj/locations/region/clusters/clust/instances/inst",
-			},
+			desc: "using the quiet-healthchecks flag",
+			args: []string{"--quiet-healthchecks",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				StaticConnectionInfo: "myfile.json",
+				QuietHealthChecks: true,
 			}),
 		},
-	}
-
-	for _, tc := range tcs {
-		t.Run(tc.desc, func(t *testing.T) {
-			c, err := invokeProxyCommand(tc.args)
-			if err != nil {
-				t.Fatalf("want error = nil, got = %v", err)
-			}
-
-			if got := c.conf; !cmp.Equal(tc.want, got) {
-				t.Fatalf("want = %#v\ngot = %#v\ndiff = %v", tc.want, got, cmp.Diff(tc.want, got))
-			}
-		})
-	}
-}
-
-func TestNewCommandWithEnvironmentConfig(t *testing.T) {
-	tcs := []struct {
-		desc     string
-		envName  string
-		envValue string
-		want     *proxy.Config
-	}{
 		{
-			desc:     "using the address envvar",
-			envName:  "ALLOYDB_PROXY_ADDRESS",
-			envValue: "0.0.0.0",
+			desc: "using the manual-start flag",
+			args: []string{"--manual-start",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				Addr: "0.0.0.0",
+				ManualStart: true,
 			}),
 		},
 		{
-			desc:     "using the port envvar",
-			envName:  "ALLOYDB_PROXY_PORT",
-			envValue: "6000",
+			desc: "using the readiness-max-connections-pct flag",
+			args: []string{"--max-connections", "100", "--readiness-max-connections-pct", "95",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				Port: 6000,
+				MaxConnections:             100,
+				ReadinessMaxConnectionsPct: 95,
 			}),
 		},
 		{
-			desc:     "using the token envvar",
-			envName:  "ALLOYDB_PROXY_TOKEN",
-			envValue: "MYCOOLTOKEN",
+			desc: "using the readiness-tcp-probe flag",
+			args: []string{"--health-check", "--readiness-tcp-probe",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				Token: "MYCOOLTOKEN",
+				HealthCheck:       true,
+				ReadinessTCPProbe: true,
 			}),
 		},
 		{
-			desc:     "using the credentiale file envvar",
-			envName:  "ALLOYDB_PROXY_CREDENTIALS_FILE",
-			envValue: "/path/to/file",
+			desc: "using the health-check-interval flag",
+			args: []string{"--health-check", "--health-check-interval", "30s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				CredentialsFile: "/path/to/file",
+				HealthCheck:         true,
+				HealthCheckInterval: 30 * time.Second,
 			}),
 		},
 		{
-			desc:     "using the JSON credentials",
-			envName:  "ALLOYDB_PROXY_JSON_CREDENTIALS",
-			envValue: `{"json":"goes-here"}`,
+			desc: "using the deployment-label flag",
+			args: []string{"--deployment-label", "prod",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				CredentialsJSON: `{"json":"goes-here"}`,
+				DeploymentLabel: "prod",
 			}),
 		},
 		{
-			desc:     "using the gcloud auth envvar",
-			envName:  "ALLOYDB_PROXY_GCLOUD_AUTH",
-			envValue: "true",
+			desc: "using the telemetry-labels flag",
+			args: []string{"--telemetry-labels", "team=data,env=prod",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				GcloudAuth: true,
+				TelemetryLabels: map[string]string{"team": "data", "env": "prod"},
 			}),
 		},
 		{
-			desc:     "using the public-ip envvar",
-			envName:  "ALLOYDB_PROXY_PUBLIC_IP",
-			envValue: "true",
+			desc: "using the http-read-header-timeout flag",
+			args: []string{"--http-read-header-timeout", "5s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				PublicIP: true,
+				HTTPReadHeaderTimeout: 5 * time.Second,
 			}),
 		},
 		{
-			desc:     "using the api-endpoint envvar",
-			envName:  "ALLOYDB_PROXY_ALLOYDBADMIN_API_ENDPOINT",
-			envValue: "https://test.googleapis.com/",
+			desc: "using the pid-file flag",
+			args: []string{"--pid-file", "/tmp/proxy.pid",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				APIEndpointURL: "https://test.googleapis.com",
+				PIDFile: "/tmp/proxy.pid",
 			}),
 		},
 		{
-			desc:     "using the unix socket envvar",
-			envName:  "ALLOYDB_PROXY_UNIX_SOCKET",
-			envValue: "/path/to/dir/",
+			desc: "using the disable-ipv6 flag",
+			args: []string{"--disable-ipv6",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				UnixSocket: "/path/to/dir/",
+				DisableIPv6: true,
 			}),
 		},
 		{
-			desc:     "enabling structured logging",
-			envName:  "ALLOYDB_PROXY_STRUCTURED_LOGS",
-			envValue: "true",
+			desc: "using the bind-retries flag",
+			args: []string{"--bind-retries", "3",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				StructuredLogs: true,
+				BindRetries: 3,
 			}),
 		},
 		{
-			desc:     "using the max connections envvar",
-			envName:  "ALLOYDB_PROXY_MAX_CONNECTIONS",
-			envValue: "1",
+			desc: "using the region-alias flag",
+			args: []string{"--region-alias", "us=us-central1,eu=europe-west1",
+				"projects/proj/locations/us/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				MaxConnections: 1,
+				RegionAliases: map[string]string{
+					"us": "us-central1",
+					"eu": "europe-west1",
+				},
+				Instances: []proxy.InstanceConnConfig{{
+					Name: "projects/proj/locations/us-central1/clusters/clust/instances/inst",
+				}},
 			}),
 		},
 		{
-			desc:     "using wait after signterm envvar",
-			envName:  "ALLOYDB_PROXY_MAX_SIGTERM_DELAY",
-			envValue: "10s",
+			desc: "using the region-alias flag with an unaliased region",
+			args: []string{"--region-alias", "us=us-central1",
+				"projects/proj/locations/asia-east1/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				WaitOnClose: 10 * time.Second,
+				RegionAliases: map[string]string{"us": "us-central1"},
+				Instances: []proxy.InstanceConnConfig{{
+					Name: "projects/proj/locations/asia-east1/clusters/clust/instances/inst",
+				}},
 			}),
 		},
 		{
-			desc:     "using the imopersonate service accounn envvar",
-			envName:  "ALLOYDB_PROXY_IMPERSONATE_SERVICE_ACCOUNT",
-			envValue: "sv1@developer.gserviceaccount.com",
+			desc: "using the max-connections-queue flag",
+			args: []string{"--max-connections", "10", "--max-connections-queue", "5s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				ImpersonationChain: "sv1@developer.gserviceaccount.com",
+				MaxConnections:      10,
+				MaxConnectionsQueue: 5 * time.Second,
 			}),
 		},
 		{
-			desc:     "using the disable traces envvar",
-			envName:  "ALLOYDB_PROXY_DISABLE_TRACES",
-			envValue: "true",
+			desc: "using the listen-network flag",
+			args: []string{"--listen-network", "tcp6", "--address", "::1",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				DisableTraces: true,
+				Addr:          "::1",
+				ListenNetwork: "tcp6",
 			}),
 		},
 		{
-			desc:     "using the telemetry sample rate envvar",
-			envName:  "ALLOYDB_PROXY_TELEMETRY_SAMPLE_RATE",
-			envValue: "500",
+			desc: "using the connection-audit-file flag",
+			args: []string{"--connection-audit-file", "/tmp/audit.log",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 			want: withDefaults(&proxy.Config{
-				TelemetryTracingSampleRate: 500,
+				ConnectionAuditFile: "/tmp/audit.log",
 			}),
 		},
 		{
-			desc:     "using the disable metrics envvar",
+			desc: "using the http-shutdown-timeout flag",
+			args: []string{"--http-shutdown-timeout", "5s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				HTTPShutdownTimeout: 5 * time.Second,
+			}),
+		},
+		{
+			desc: "using the new connection rate flags",
+			args: []string{"--new-connection-rate", "2.5", "--new-connection-burst", "5",
+				"--new-connection-rate-per-instance",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				NewConnectionRate:            2.5,
+				NewConnectionBurst:           5,
+				NewConnectionRatePerInstance: true,
+			}),
+		},
+		{
+			desc: "using the friendly-max-conn-error flag",
+			args: []string{"--max-connections", "10", "--friendly-max-conn-error",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				MaxConnections:       10,
+				FriendlyMaxConnError: true,
+			}),
+		},
+		{
+			desc: "using the startup-delay flag",
+			args: []string{"--startup-delay", "500ms",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				StartupDelay: 500 * time.Millisecond,
+			}),
+		},
+		{
+			desc: "using the max-startup-time flag",
+			args: []string{"--max-startup-time", "2m",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				MaxStartupTime: 2 * time.Minute,
+			}),
+		},
+		{
+			desc: "using the refuse-new-on-sigterm flag",
+			args: []string{"--refuse-new-on-sigterm", "--min-sigterm-delay", "10s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				RefuseNewOnSigterm: true,
+				WaitBeforeClose:    10 * time.Second,
+			}),
+		},
+		{
+			desc: "using the max-connection-lifetime flag",
+			args: []string{"--max-connection-lifetime", "1h",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				MaxConnectionLifetime: time.Hour,
+			}),
+		},
+		{
+			desc: "using the dual-listener flag",
+			args: []string{"--dual-listener", "--unix-socket", "/path/to/dir",
+				"--address", "0.0.0.0", "--port", "6000",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				DualListener: true,
+				UnixSocket:   "/path/to/dir",
+				Addr:         "0.0.0.0",
+				Port:         6000,
+			}),
+		},
+		{
+			desc: "using the admin-api-proxy-url flag",
+			args: []string{"--admin-api-proxy-url", "http://proxy.example.com:3128",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				AdminAPIProxyURL: "http://proxy.example.com:3128",
+			}),
+		},
+		{
+			desc: "using the run-connection-test flag",
+			args: []string{"--run-connection-test",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				RunConnectionTest: true,
+			}),
+		},
+		{
+			desc: "using the log-instances flag",
+			args: []string{"--log-instances", "proj.region.clust.inst,projects/proj/locations/region/clusters/clust/instances/other",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				LogInstances: []string{
+					"proj.region.clust.inst",
+					"projects/proj/locations/region/clusters/clust/instances/other",
+				},
+			}),
+		},
+		{
+			desc: "using the debug logs flag",
+			args: []string{"--debug-logs",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+			want: withDefaults(&proxy.Config{
+				DebugLogs: true,
+			}),
+		},
+		{
+			desc: "using the static connection info flag",
+			args: []string{
+				"--static-connection-info",
+				"myfile.json",
+				"projects/proj/locations/region/clusters/clust/instances/inst",
+			},
+			want: withDefaults(&proxy.Config{
+				StaticConnectionInfo: "myfile.json",
+			}),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			c, err := invokeProxyCommand(tc.args)
+			if err != nil {
+				t.Fatalf("want error = nil, got = %v", err)
+			}
+
+			if got := c.conf; !cmp.Equal(tc.want, got) {
+				t.Fatalf("want = %#v\ngot = %#v\ndiff = %v", tc.want, got, cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+func TestNewCommandWithEnvironmentConfig(t *testing.T) {
+	tcs := []struct {
+		desc     string
+		envName  string
+		envValue string
+		want     *proxy.Config
+	}{
+		{
+			desc:     "using the address envvar",
+			envName:  "ALLOYDB_PROXY_ADDRESS",
+			envValue: "0.0.0.0",
+			want: withDefaults(&proxy.Config{
+				Addr: "0.0.0.0",
+			}),
+		},
+		{
+			desc:     "using the port envvar",
+			envName:  "ALLOYDB_PROXY_PORT",
+			envValue: "6000",
+			want: withDefaults(&proxy.Config{
+				Port: 6000,
+			}),
+		},
+		{
+			desc:     "using the token envvar",
+			envName:  "ALLOYDB_PROXY_TOKEN",
+			envValue: "MYCOOLTOKEN",
+			want: withDefaults(&proxy.Config{
+				Token: "MYCOOLTOKEN",
+			}),
+		},
+		{
+			desc:     "using the credentiale file envvar",
+			envName:  "ALLOYDB_PROXY_CREDENTIALS_FILE",
+			envValue: "/path/to/file",
+			want: withDefaults(&proxy.Config{
+				CredentialsFile: "/path/to/file",
+			}),
+		},
+		{
+			desc:     "using the JSON credentials",
+			envName:  "ALLOYDB_PROXY_JSON_CREDENTIALS",
+			envValue: `{"json":"goes-here"}`,
+			want: withDefaults(&proxy.Config{
+				CredentialsJSON: `{"json":"goes-here"}`,
+			}),
+		},
+		{
+			desc:     "using the gcloud auth envvar",
+			envName:  "ALLOYDB_PROXY_GCLOUD_AUTH",
+			envValue: "true",
+			want: withDefaults(&proxy.Config{
+				GcloudAuth: true,
+			}),
+		},
+		{
+			desc:     "using the public-ip envvar",
+			envName:  "ALLOYDB_PROXY_PUBLIC_IP",
+			envValue: "true",
+			want: withDefaults(&proxy.Config{
+				PublicIP: true,
+			}),
+		},
+		{
+			desc:     "using the api-endpoint envvar",
+			envName:  "ALLOYDB_PROXY_ALLOYDBADMIN_API_ENDPOINT",
+			envValue: "https://test.googleapis.com/",
+			want: withDefaults(&proxy.Config{
+				APIEndpointURL: "https://test.googleapis.com",
+			}),
+		},
+		{
+			desc:     "using the unix socket envvar",
+			envName:  "ALLOYDB_PROXY_UNIX_SOCKET",
+			envValue: "/path/to/dir/",
+			want: withDefaults(&proxy.Config{
+				UnixSocket: "/path/to/dir/",
+			}),
+		},
+		{
+			desc:     "enabling structured logging",
+			envName:  "ALLOYDB_PROXY_STRUCTURED_LOGS",
+			envValue: "true",
+			want: withDefaults(&proxy.Config{
+				StructuredLogs: true,
+			}),
+		},
+		{
+			desc:     "using the max connections envvar",
+			envName:  "ALLOYDB_PROXY_MAX_CONNECTIONS",
+			envValue: "1",
+			want: withDefaults(&proxy.Config{
+				MaxConnections: 1,
+			}),
+		},
+		{
+			desc:     "using wait after signterm envvar",
+			envName:  "ALLOYDB_PROXY_MAX_SIGTERM_DELAY",
+			envValue: "10s",
+			want: withDefaults(&proxy.Config{
+				WaitOnClose: 10 * time.Second,
+			}),
+		},
+		{
+			desc:     "using the imopersonate service accounn envvar",
+			envName:  "ALLOYDB_PROXY_IMPERSONATE_SERVICE_ACCOUNT",
+			envValue: "sv1@developer.gserviceaccount.com",
+			want: withDefaults(&proxy.Config{
+				ImpersonationChain: "sv1@developer.gserviceaccount.com",
+			}),
+		},
+		{
+			desc:     "using the disable traces envvar",
+			envName:  "ALLOYDB_PROXY_DISABLE_TRACES",
+			envValue: "true",
+			want: withDefaults(&proxy.Config{
+				DisableTraces: true,
+			}),
+		},
+		{
+			desc:     "using the telemetry sample rate envvar",
+			envName:  "ALLOYDB_PROXY_TELEMETRY_SAMPLE_RATE",
+			envValue: "500",
+			want: withDefaults(&proxy.Config{
+				TelemetryTracingSampleRate: 500,
+			}),
+		},
+		{
+			desc:     "using the disable metrics envvar",
 			envName:  "ALLOYDB_PROXY_DISABLE_METRICS",
 			envValue: "true",
 			want: withDefaults(&proxy.Config{
@@ -687,6 +1216,14 @@ func TestNewCommandWithEnvironmentConfig(t *testing.T) {
 				TelemetryProject: "mycoolproject",
 			}),
 		},
+		{
+			desc:     "using the otlp endpoint envvar",
+			envName:  "ALLOYDB_PROXY_OTLP_ENDPOINT",
+			envValue: "localhost:4317",
+			want: withDefaults(&proxy.Config{
+				OTLPEndpoint: "localhost:4317",
+			}),
+		},
 		{
 			desc:     "using the telemetry prefix envvar",
 			envName:  "ALLOYDB_PROXY_TELEMETRY_PREFIX",
@@ -711,6 +1248,14 @@ func TestNewCommandWithEnvironmentConfig(t *testing.T) {
 				PrometheusNamespace: "myns",
 			}),
 		},
+		{
+			desc:     "using the metrics path envvar",
+			envName:  "ALLOYDB_PROXY_METRICS_PATH",
+			envValue: "/custom",
+			want: withDefaults(&proxy.Config{
+				MetricsPath: "/custom",
+			}),
+		},
 		{
 			desc:     "using the health check envvar",
 			envName:  "ALLOYDB_PROXY_HEALTH_CHECK",
@@ -825,6 +1370,26 @@ func TestNewCommandWithEnvironmentConfigInstanceConnectionName(t *testing.T) {
 				{Name: u + "1"},
 			}}),
 		},
+		{
+			desc: "with query params",
+			env: map[string]string{
+				"ALLOYDB_PROXY_INSTANCE_URI": u + "?auto-iam-authn=true&port=6000",
+			},
+			want: withDefaults(&proxy.Config{Instances: []proxy.InstanceConnConfig{
+				{Name: u, AutoIAMAuthN: pointer(true), Port: 6000},
+			}}),
+		},
+		{
+			desc: "with query params on indexed instance connection names",
+			env: map[string]string{
+				"ALLOYDB_PROXY_INSTANCE_URI_0": u + "0?port=6000",
+				"ALLOYDB_PROXY_INSTANCE_URI_1": u + "1?auto-iam-authn=true",
+			},
+			want: withDefaults(&proxy.Config{Instances: []proxy.InstanceConnConfig{
+				{Name: u + "0", Port: 6000},
+				{Name: u + "1", AutoIAMAuthN: pointer(true)},
+			}}),
+		},
 		{
 			desc: "when only an index instance connection name is defined",
 			env: map[string]string{
@@ -918,32 +1483,189 @@ func TestNewCommandWithErrors(t *testing.T) {
 			args: []string{"projects/proj/locations/region/clusters/clust/"},
 		},
 		{
-			desc: "when the query string is bogus",
-			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?%=foo"},
+			desc: "when the instance uri does not match the expected format",
+			args: []string{"projects/proj/locations/region/clusters/clust/nodes/node"},
 		},
 		{
-			desc: "when the address query param is empty",
-			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?address="},
+			desc: "when the trace-sample-rate query param is not an integer",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?trace-sample-rate=0.5"},
 		},
 		{
-			desc: "using the address flag with a bad IP address",
-			args: []string{"--address", "bogus", "projects/proj/locations/region/clusters/clust/instances/inst"},
+			desc: "when the trace-sample-rate query param is zero",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?trace-sample-rate=0"},
 		},
 		{
-			desc: "when the address query param is not an IP address",
-			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?address=世界"},
+			desc: "when the connect-timeout query param is not a duration",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?connect-timeout=5"},
 		},
 		{
-			desc: "when the address query param contains multiple values",
-			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?address=0.0.0.0&address=1.1.1.1&address=2.2.2.2"},
+			desc: "when the connect-timeout query param is negative",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?connect-timeout=-1s"},
 		},
 		{
-			desc: "when the query string is invalid",
-			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?address=1.1.1.1?foo=2.2.2.2"},
+			desc: "when the telemetry-labels are not key=value pairs",
+			args: []string{"--telemetry-labels", "team",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
 		},
 		{
-			desc: "when the port query param contains multiple values",
-			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?port=1&port=2"},
+			desc: "when a telemetry-labels key is invalid",
+			args: []string{"--telemetry-labels", "my-team=data",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when the telemetry-labels conflict with the deployment-label",
+			args: []string{"--telemetry-labels", "deployment=prod", "--deployment-label", "staging",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when the otlp-endpoint is used with the telemetry-project",
+			args: []string{"--otlp-endpoint", "localhost:4317", "--telemetry-project", "proj",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when the otlp-endpoint is not a host:port",
+			args: []string{"--otlp-endpoint", "localhost",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when the http-shutdown-timeout is zero",
+			args: []string{"--http-shutdown-timeout", "0s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when the metrics-path does not start with a slash",
+			args: []string{"--prometheus", "--metrics-path", "metrics",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when the metrics-path conflicts with the health check",
+			args: []string{"--prometheus", "--health-check", "--metrics-path", "/readiness",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when the log-timestamp-format is not a layout",
+			args: []string{"--log-timestamp-format", "rfc3339-nano",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when the dial-timeout is zero",
+			args: []string{"--dial-timeout", "0s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when the query string is bogus",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?%=foo"},
+		},
+		{
+			desc: "when the address query param is empty",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?address="},
+		},
+		{
+			desc: "using the address flag with a bad IP address",
+			args: []string{"--address", "bogus", "projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when the address query param is not an IP address",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?address=世界"},
+		},
+		{
+			desc: "using the disable-ipv6 flag with an IPv6 address",
+			args: []string{"--disable-ipv6", "--address", "::1",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using the disable-ipv6 flag with an IPv6 address query param",
+			args: []string{"--disable-ipv6",
+				"projects/proj/locations/region/clusters/clust/instances/inst?address=::1"},
+		},
+		{
+			desc: "using the region-alias flag without a region",
+			args: []string{"--region-alias", "us",
+				"projects/proj/locations/us/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using the region-alias flag with an invalid region",
+			args: []string{"--region-alias", "us=US Central",
+				"projects/proj/locations/us/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using the region-alias flag with an empty alias",
+			args: []string{"--region-alias", "=us-central1",
+				"projects/proj/locations/us/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using a negative bind-retries",
+			args: []string{"--bind-retries", "-1",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using a negative max-connections-queue",
+			args: []string{"--max-connections", "10", "--max-connections-queue", "-1s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using the append-application-name flag with a NUL character",
+			args: []string{"--append-application-name=app\x00",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using health-check-startup-successes less than 1",
+			args: []string{"--health-check", "--health-check-on-startup-only",
+				"--health-check-startup-successes", "0",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using a negative health-check-startup-timeout",
+			args: []string{"--health-check", "--health-check-on-startup-only",
+				"--health-check-startup-timeout", "-1s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using health-check-on-startup-only with prometheus",
+			args: []string{"--health-check", "--health-check-on-startup-only", "--prometheus",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using the log-format flag with an unknown format",
+			args: []string{"--log-format", "xml",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using the log-format flag with logfmt and structured logs",
+			args: []string{"--log-format", "logfmt", "--structured-logs",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using the listen-network flag with an unknown network",
+			args: []string{"--listen-network", "udp",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using the listen-network flag with tcp6 and an IPv4 address",
+			args: []string{"--listen-network", "tcp6",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using the listen-network flag with tcp4 and an IPv6 address query param",
+			args: []string{"--listen-network", "tcp4",
+				"projects/proj/locations/region/clusters/clust/instances/inst?address=::1"},
+		},
+		{
+			desc: "using the listen-network flag with tcp6 and disable-ipv6",
+			args: []string{"--listen-network", "tcp6", "--disable-ipv6", "--address", "::1",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when the address query param contains multiple values",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?address=0.0.0.0&address=1.1.1.1&address=2.2.2.2"},
+		},
+		{
+			desc: "when the query string is invalid",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?address=1.1.1.1?foo=2.2.2.2"},
+		},
+		{
+			desc: "when the port query param contains multiple values",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?port=1&port=2"},
 		},
 		{
 			desc: "when the port query param is not a number",
@@ -962,6 +1684,36 @@ func TestNewCommandWithErrors(t *testing.T) {
 				"--gcloud-auth",
 				"projects/proj/locations/region/clusters/clust/instances/inst"},
 		},
+		{
+			desc: "when both token stdin and token are set",
+			args: []string{
+				"--token-stdin", "--token", "my-token",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when both token stdin and credentials file are set",
+			args: []string{
+				"--token-stdin", "--credentials-file", "/path/to/file",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when both token stdin and json credentials are set",
+			args: []string{
+				"--token-stdin", "--json-credentials", `{"json":"here"}`,
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when both token stdin and gcloud auth are set",
+			args: []string{
+				"--token-stdin", "--gcloud-auth",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "when reload credentials is set without a credentials file",
+			args: []string{
+				"--reload-credentials", "--json-credentials", `{"json":"here"}`,
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
 		{
 			desc: "when both gcloud auth and credentials file are set",
 			args: []string{
@@ -1022,6 +1774,85 @@ func TestNewCommandWithErrors(t *testing.T) {
 			desc: "using the unix socket and port query params",
 			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?unix-socket=/path&port=5000"},
 		},
+		{
+			desc: "using client-tls-client-ca without client-tls-cert",
+			args: []string{
+				"--client-tls-client-ca", "/path/to/ca.pem",
+				"projects/proj/locations/region/clusters/clust/instances/inst",
+			},
+		},
+		{
+			desc: "readiness max connections pct above 100",
+			args: []string{"--max-connections", "100", "--readiness-max-connections-pct", "101",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "admin-api-proxy-url without a host",
+			args: []string{"--admin-api-proxy-url", "proxy.example.com",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "admin-api-proxy-url with an unsupported scheme",
+			args: []string{"--admin-api-proxy-url", "ftp://proxy.example.com",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "user-agent-suffix with a space",
+			args: []string{"--user-agent-suffix", "prod east",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "user-agent-suffix with a disallowed character",
+			args: []string{"--user-agent-suffix", "prod;east",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "empty user-agent-override",
+			args: []string{"--user-agent-override", " ",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "negative health check interval",
+			args: []string{"--health-check", "--health-check-interval", "-1s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "negative new connection rate",
+			args: []string{"--new-connection-rate", "-1",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "new connection burst less than 1",
+			args: []string{"--new-connection-rate", "1", "--new-connection-burst", "0",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using the public-ip and psc query params",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?public-ip=true&psc=true"},
+		},
+		{
+			desc: "using the private-ip and public-ip query params",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?private-ip=true&public-ip=true"},
+		},
+		{
+			desc: "using the private-ip and psc query params",
+			args: []string{"projects/proj/locations/region/clusters/clust/instances/inst?private-ip=true&psc=true"},
+		},
+		{
+			desc: "using the public-ip and psc flags",
+			args: []string{"--public-ip", "--psc",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using the private-ip and public-ip flags",
+			args: []string{"--private-ip", "--public-ip",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using the private-ip and psc flags",
+			args: []string{"--private-ip", "--psc",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
 		{
 			desc: "using an invalid url for host flag",
 			args: []string{"--host", "https://invalid:url[/]",
@@ -1031,6 +1862,55 @@ func TestNewCommandWithErrors(t *testing.T) {
 			desc: "using fuse-tmp-dir without fuse",
 			args: []string{"--fuse-tmp-dir", "/mydir"},
 		},
+		{
+			desc: "negative startup-delay",
+			args: []string{"--startup-delay", "-1s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "negative max-startup-time",
+			args: []string{"--max-startup-time", "-1s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "negative max-connection-lifetime",
+			args: []string{"--max-connection-lifetime", "-1s",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using dual-listener without unix-socket",
+			args: []string{"--dual-listener",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using fuse-max-instances without fuse",
+			args: []string{"--fuse-max-instances", "10",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using fuse-no-autoconnect without fuse",
+			args: []string{"--fuse-no-autoconnect",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using fuse-socket-suffix without fuse",
+			args: []string{"--fuse-socket-suffix", "6432",
+				"projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		{
+			desc: "using client-tls-cert without client-tls-key",
+			args: []string{
+				"--client-tls-cert", "/path/to/cert.pem",
+				"projects/proj/locations/region/clusters/clust/instances/inst",
+			},
+		},
+		{
+			desc: "using client-tls-key without client-tls-cert",
+			args: []string{
+				"--client-tls-key", "/path/to/key.pem",
+				"projects/proj/locations/region/clusters/clust/instances/inst",
+			},
+		},
 		{
 			desc: "run-connection-test with fuse",
 			args: []string{
@@ -1049,6 +1929,67 @@ func TestNewCommandWithErrors(t *testing.T) {
 	}
 }
 
+func TestTokenStdin(t *testing.T) {
+	pipe := func(token string) io.Reader {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { r.Close() })
+		go func() {
+			defer w.Close()
+			_, _ = io.WriteString(w, token)
+		}()
+		return r
+	}
+	tcs := []struct {
+		desc    string
+		stdin   io.Reader
+		want    string
+		wantErr string
+	}{
+		{
+			desc:  "from a pipe",
+			stdin: pipe("my-token\n"),
+			want:  "my-token",
+		},
+		{
+			desc:  "from a reader with surrounding whitespace",
+			stdin: strings.NewReader("  \tmy-token \r\n\n"),
+			want:  "my-token",
+		},
+		{
+			desc:    "when stdin is empty",
+			stdin:   strings.NewReader(" \n"),
+			wantErr: "--token-stdin read an empty token from standard input",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := NewCommand()
+			c.SilenceUsage = true
+			c.SilenceErrors = true
+			c.RunE = func(*cobra.Command, []string) error { return nil }
+			c.SetIn(tc.stdin)
+			c.SetArgs([]string{"--token-stdin", sampleURI})
+
+			err := c.Execute()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("want error containing %q, got = %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want error = nil, got = %v", err)
+			}
+			if c.conf.Token != tc.want {
+				t.Fatalf("want token = %q, got = %q", tc.want, c.conf.Token)
+			}
+		})
+	}
+}
+
 type spyDialer struct {
 	mu  sync.Mutex
 	got string
@@ -1125,37 +2066,274 @@ func TestCommandWithCustomDialer(t *testing.T) {
 	}, 10)
 }
 
-func tryDial(method, addr string) (*http.Response, error) {
-	var (
-		resp     *http.Response
-		attempts int
-		err      error
-	)
-	u, err := url.Parse(addr)
-	if err != nil {
-		return nil, err
-	}
-	req := &http.Request{Method: method, URL: u}
-	for {
-		if attempts > 10 {
-			return resp, err
-		}
-		resp, err = http.DefaultClient.Do(req)
-		if err != nil {
-			attempts++
-			time.Sleep(time.Second)
-			continue
-		}
-		return resp, err
-	}
-}
-
-func TestPrometheusMetricsEndpoint(t *testing.T) {
+func TestRunWithResult(t *testing.T) {
 	c := NewCommand(WithDialer(&spyDialer{}))
-	// Keep the test output quiet
 	c.SilenceUsage = true
 	c.SilenceErrors = true
-	c.SetArgs([]string{"--prometheus", "projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance?port=5321"})
+	c.SetArgs([]string{
+		"--port", "7022",
+		"projects/proj/locations/region/clusters/clust/instances/inst1",
+		"projects/proj/locations/region/clusters/clust/instances/inst2",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	type runResult struct {
+		res *RunResult
+		err error
+	}
+	resCh := make(chan runResult, 1)
+	go func() {
+		res, err := c.RunWithResult(ctx)
+		resCh <- runResult{res: res, err: err}
+	}()
+
+	// Wait for the Proxy to start before stopping it.
+	for i := 0; ; i++ {
+		conn, err := net.Dial("tcp", "127.0.0.1:7023")
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if i == 10 {
+			t.Fatalf("proxy did not start: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	cancel()
+
+	r := <-resCh
+	if !errors.Is(r.err, errSigInt) {
+		t.Fatalf("want error = %v, got = %v", errSigInt, r.err)
+	}
+	want := &RunResult{
+		Addrs:     []string{"127.0.0.1:7022", "127.0.0.1:7023"},
+		Instances: 2,
+	}
+	if diff := cmp.Diff(want, r.res); diff != "" {
+		t.Fatalf("RunResult mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestFUSEFallback(t *testing.T) {
+	orig := supportsFUSE
+	supportsFUSE = func() error { return errors.New("fusermount not found") }
+	defer func() { supportsFUSE = orig }()
+	fuseDir := t.TempDir()
+
+	t.Run("without fallback", func(t *testing.T) {
+		_, err := invokeProxyCommand([]string{"--fuse", fuseDir, sampleURI})
+		if err == nil || !strings.Contains(err.Error(), "--fuse is not supported") {
+			t.Fatalf("want --fuse is not supported error, got = %v", err)
+		}
+	})
+
+	t.Run("with fallback and instance URIs", func(t *testing.T) {
+		c, err := invokeProxyCommand([]string{"--fuse", fuseDir, "--fuse-fallback", sampleURI})
+		if err != nil {
+			t.Fatalf("want error = nil, got = %v", err)
+		}
+		if c.conf.FUSEDir != "" {
+			t.Fatalf("want FUSE disabled, got FUSEDir = %q", c.conf.FUSEDir)
+		}
+		if len(c.conf.Instances) != 1 || c.conf.Instances[0].Name != sampleURI {
+			t.Fatalf("want instances = [%v], got = %v", sampleURI, c.conf.Instances)
+		}
+	})
+
+	t.Run("with fallback and no instance URIs", func(t *testing.T) {
+		_, err := invokeProxyCommand([]string{"--fuse", fuseDir, "--fuse-fallback"})
+		if err == nil || !strings.Contains(err.Error(), "requires instance URIs") {
+			t.Fatalf("want requires instance URIs error, got = %v", err)
+		}
+	})
+}
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewCommand(WithDialer(&spyDialer{}), WithContext(ctx))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--port", "7025", sampleURI})
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.Execute() }()
+
+	// Wait for the Proxy to start before canceling the injected context.
+	for i := 0; ; i++ {
+		conn, err := net.Dial("tcp", "127.0.0.1:7025")
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if i == 10 {
+			t.Fatalf("proxy did not start: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, errSigInt) {
+			t.Fatalf("want error = %v, got = %v", errSigInt, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy did not stop after the injected context was canceled")
+	}
+	// The listener is closed on shutdown.
+	if conn, err := net.Dial("tcp", "127.0.0.1:7025"); err == nil {
+		conn.Close()
+		t.Fatal("want Dial error after shutdown, got nil")
+	}
+}
+
+func TestWithContextYieldsToExecuteContext(t *testing.T) {
+	injected, cancelInjected := context.WithCancel(context.Background())
+	defer cancelInjected()
+	c := NewCommand(WithDialer(&spyDialer{}), WithContext(injected))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--port", "7028", sampleURI})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.ExecuteContext(ctx) }()
+
+	for i := 0; ; i++ {
+		conn, err := net.Dial("tcp", "127.0.0.1:7028")
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if i == 10 {
+			t.Fatalf("proxy did not start: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// Only the context passed to ExecuteContext stops the Proxy.
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, errSigInt) {
+			t.Fatalf("want error = %v, got = %v", errSigInt, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy did not stop after the ExecuteContext context was canceled")
+	}
+}
+
+func TestRunWithResultStartupError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:7024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--port", "7024", sampleURI})
+
+	res, err := c.RunWithResult(context.Background())
+	if err == nil {
+		t.Fatal("want error, got nil")
+	}
+	if res.StartupErr != err {
+		t.Fatalf("StartupErr: want = %v, got = %v", err, res.StartupErr)
+	}
+	if len(res.Addrs) != 0 {
+		t.Fatalf("Addrs: want none, got = %v", res.Addrs)
+	}
+	if want, got := 1, res.Instances; want != got {
+		t.Fatalf("Instances: want = %v, got = %v", want, got)
+	}
+}
+
+func TestValidateOnly(t *testing.T) {
+	s := &spyDialer{}
+	c := NewCommand(WithDialer(s))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	var out bytes.Buffer
+	c.SetOut(&out)
+	c.SetArgs([]string{
+		"--validate-only", "--token", "my-token", "--port", "7002", sampleURI,
+	})
+
+	if err := c.Execute(); err != nil {
+		t.Fatalf("want error = nil, got = %v", err)
+	}
+
+	var got proxy.Config
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode printed config %q: %v", out.String(), err)
+	}
+	if got.Port != 7002 {
+		t.Errorf("want port = 7002, got = %v", got.Port)
+	}
+	if len(got.Instances) != 1 || got.Instances[0].Name != sampleURI {
+		t.Errorf("want instances = %v, got = %v", sampleURI, got.Instances)
+	}
+	if strings.Contains(out.String(), "my-token") {
+		t.Errorf("want token omitted from output, got = %v", out.String())
+	}
+	if inst := s.instance(); inst != "" {
+		t.Errorf("want no dial attempts, got dial to %v", inst)
+	}
+	if conn, err := net.Dial("tcp", "127.0.0.1:7002"); err == nil {
+		conn.Close()
+		t.Error("want no listener, but listener accepted a connection")
+	}
+}
+
+func TestValidateOnlyWithInvalidConfig(t *testing.T) {
+	_, err := invokeProxyCommand([]string{
+		"--validate-only", "--token", "my-token",
+		"--credentials-file", "/path/to/file", sampleURI,
+	})
+	if err == nil {
+		t.Fatal("want error, got nil")
+	}
+	if !strings.Contains(err.Error(), "cannot specify --token and --credentials-file flags at the same time") {
+		t.Fatalf("want descriptive error, got = %v", err)
+	}
+}
+
+func tryDial(method, addr string) (*http.Response, error) {
+	var (
+		resp     *http.Response
+		attempts int
+		err      error
+	)
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	req := &http.Request{Method: method, URL: u}
+	for {
+		if attempts > 10 {
+			return resp, err
+		}
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			attempts++
+			time.Sleep(time.Second)
+			continue
+		}
+		return resp, err
+	}
+}
+
+func TestPrometheusMetricsEndpoint(t *testing.T) {
+	c := NewCommand(WithDialer(&spyDialer{}))
+	// Keep the test output quiet
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--prometheus", "projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance?port=5321"})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -1173,6 +2351,407 @@ func TestPrometheusMetricsEndpoint(t *testing.T) {
 	}
 }
 
+func TestPrometheusMetricsEndpointWithCustomPath(t *testing.T) {
+	c := NewCommand(WithDialer(&spyDialer{}))
+	// Keep the test output quiet
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--prometheus", "--metrics-path", "/custom/metrics", "--http-port", "9099",
+		"projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance?port=5329"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	go c.ExecuteContext(ctx)
+
+	resp, err := tryDial("GET", "http://localhost:9099/custom/metrics")
+	if err != nil {
+		t.Fatalf("failed to dial metrics endpoint: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a 200 status, got = %v", resp.StatusCode)
+	}
+
+	resp, err = tryDial("GET", "http://localhost:9099/metrics")
+	if err != nil {
+		t.Fatalf("failed to dial default metrics path: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a 404 status, got = %v", resp.StatusCode)
+	}
+}
+
+// pipeDialer returns connections that stay open until closed by the caller.
+type pipeDialer struct {
+	spyDialer
+}
+
+func (*pipeDialer) Dial(_ context.Context, _ string, _ ...alloydbconn.DialOption) (net.Conn, error) {
+	conn, _ := net.Pipe()
+	return conn, nil
+}
+
+func TestFollowParent(t *testing.T) {
+	s := followParent(trace.AlwaysSample())
+	tcs := []struct {
+		desc   string
+		parent trace.SpanContext
+		want   bool
+	}{
+		{
+			desc: "without a parent",
+			want: true,
+		},
+		{
+			desc:   "with a sampled parent",
+			parent: trace.SpanContext{TraceID: trace.TraceID{1}, TraceOptions: 1},
+			want:   true,
+		},
+		{
+			desc:   "with an unsampled parent",
+			parent: trace.SpanContext{TraceID: trace.TraceID{1}},
+			want:   false,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := s(trace.SamplingParameters{ParentContext: tc.parent}).Sample
+			if got != tc.want {
+				t.Fatalf("want sample = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStartTelemetryCanBeReinvoked(t *testing.T) {
+	conf := &proxy.Config{Prometheus: true}
+	for i := 0; i < 2; i++ {
+		prom, stop, err := startTelemetry(conf)
+		if err != nil {
+			t.Fatalf("startTelemetry error: %v", err)
+		}
+		// Each call builds a new Prometheus exporter.
+		rec := httptest.NewRecorder()
+		prom.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("want Prometheus status = 200, got = %v", rec.Code)
+		}
+		stop()
+	}
+
+	prom, stop, err := startTelemetry(&proxy.Config{})
+	if err != nil {
+		t.Fatalf("startTelemetry error: %v", err)
+	}
+	defer stop()
+	if prom != nil {
+		t.Fatal("want no Prometheus handler without --prometheus")
+	}
+}
+
+// otlpReceiver is a stub OpenTelemetry collector that records the names of
+// the spans and metrics it receives.
+type otlpReceiver struct {
+	coltracepb.UnimplementedTraceServiceServer
+	colmetricpb.UnimplementedMetricsServiceServer
+
+	mu      sync.Mutex
+	spans   []string
+	metrics []string
+	// metricAttrs holds the resource attributes of the exported metrics.
+	metricAttrs map[string]string
+}
+
+func (r *otlpReceiver) Export(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			for _, s := range ss.GetSpans() {
+				r.spans = append(r.spans, s.GetName())
+			}
+		}
+	}
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+// metricsService adapts otlpReceiver to the metrics service, whose Export
+// method conflicts with the trace service's.
+type metricsService struct {
+	*otlpReceiver
+}
+
+func (r metricsService) Export(_ context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rm := range req.GetResourceMetrics() {
+		if r.metricAttrs == nil {
+			r.metricAttrs = make(map[string]string)
+		}
+		for _, kv := range rm.GetResource().GetAttributes() {
+			r.metricAttrs[kv.GetKey()] = kv.GetValue().GetStringValue()
+		}
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				r.metrics = append(r.metrics, m.GetName())
+			}
+		}
+	}
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestStartTelemetryExportsToOTLP(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &otlpReceiver{}
+	srv := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(srv, r)
+	colmetricpb.RegisterMetricsServiceServer(srv, metricsService{r})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	_, stop, err := startTelemetry(&proxy.Config{
+		OTLPEndpoint:               lis.Addr().String(),
+		OTLPInsecure:               true,
+		TelemetryTracingSampleRate: 1,
+	})
+	if err != nil {
+		t.Fatalf("startTelemetry error: %v", err)
+	}
+
+	m := stats.Int64("test/otlp_count", "Count for the OTLP test", stats.UnitDimensionless)
+	v := &view.View{Name: "test/otlp_count", Measure: m, Aggregation: view.Count()}
+	if err := view.Register(v); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(v)
+	stats.Record(context.Background(), m.M(1))
+	_, span := trace.StartSpan(context.Background(), "test-span")
+	span.End()
+
+	// Stopping flushes the pending metrics and spans.
+	stop()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.spans, "test-span") {
+		t.Errorf("want test-span exported, got = %v", r.spans)
+	}
+	if !slices.Contains(r.metrics, "test/otlp_count") {
+		t.Errorf("want test/otlp_count exported, got = %v", r.metrics)
+	}
+}
+
+func TestStartTelemetryAddsTelemetryLabels(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &otlpReceiver{}
+	srv := grpc.NewServer()
+	colmetricpb.RegisterMetricsServiceServer(srv, metricsService{r})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	_, stop, err := startTelemetry(&proxy.Config{
+		OTLPEndpoint:    lis.Addr().String(),
+		OTLPInsecure:    true,
+		DisableTraces:   true,
+		DeploymentLabel: "prod",
+		TelemetryLabels: map[string]string{"team": "data"},
+	})
+	if err != nil {
+		t.Fatalf("startTelemetry error: %v", err)
+	}
+
+	m := stats.Int64("test/labels_count", "Count for the labels test", stats.UnitDimensionless)
+	v := &view.View{Name: "test/labels_count", Measure: m, Aggregation: view.Count()}
+	if err := view.Register(v); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(v)
+	stats.Record(context.Background(), m.M(1))
+
+	stop()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, want := range map[string]string{"deployment": "prod", "team": "data"} {
+		if got := r.metricAttrs[k]; got != want {
+			t.Errorf("metric label %q: want = %q, got = %q", k, want, got)
+		}
+	}
+}
+
+func TestPrometheusConnectionsRefusedMetric(t *testing.T) {
+	c := NewCommand(WithDialer(&pipeDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--prometheus", "--http-port", "9095", "--max-connections", "1",
+		"--deployment-label", "prod",
+		"projects/proj/locations/region/clusters/clust/instances/inst?port=5325"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.ExecuteContext(ctx)
+
+	// The metrics server starts once the listeners are bound.
+	if _, err := tryDial("GET", "http://localhost:9095/metrics"); err != nil {
+		t.Fatalf("failed to dial metrics endpoint: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", "127.0.0.1:5325")
+		if err != nil {
+			t.Fatalf("net.Dial error: %v", err)
+		}
+		defer conn.Close()
+	}
+
+	want := `alloydbconn_connections_refused{alloydb_cluster="clust",alloydb_instance="proj.region.clust.inst",alloydb_project="proj",alloydb_region="region",deployment="prod",reason="max_connections"} 1`
+	var body string
+	for i := 0; i < 10; i++ {
+		resp, err := tryDial("GET", "http://localhost:9095/metrics")
+		if err != nil {
+			t.Fatalf("failed to dial metrics endpoint: %v", err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to read metrics: %v", err)
+		}
+		if body = string(b); strings.Contains(body, want) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("want metrics to contain %q, got = %v", want, body)
+}
+
+func TestHTTPServerTimesOutSlowHeaders(t *testing.T) {
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--health-check", "--http-port", "9098",
+		"--http-read-header-timeout", "500ms",
+		"projects/proj/locations/region/clusters/clust/instances/inst?port=5328"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.ExecuteContext(ctx)
+
+	if _, err := tryDial("GET", "http://localhost:9098/liveness"); err != nil {
+		t.Fatalf("failed to dial liveness endpoint: %v", err)
+	}
+	conn, err := net.Dial("tcp", "localhost:9098")
+	if err != nil {
+		t.Fatalf("net.Dial error: %v", err)
+	}
+	defer conn.Close()
+	// Send an incomplete request header and never finish it.
+	if _, err := conn.Write([]byte("GET /liveness HTTP/1.1\r\n")); err != nil {
+		t.Fatalf("conn.Write error: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.ReadAll(conn)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		t.Fatal("want server to close slow connection, but it stayed open")
+	}
+}
+
+// waitForHTTPServerStop waits for the HTTP server at addr to stop accepting
+// connections.
+func waitForHTTPServerStop(t *testing.T, addr string) {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return
+		}
+		conn.Close()
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("want HTTP server at %v stopped, but it still accepts connections", addr)
+}
+
+func TestHealthCheckOnStartupOnly(t *testing.T) {
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--health-check", "--http-port", "9199",
+		"--health-check-on-startup-only", "--health-check-startup-successes", "2",
+		"projects/proj/locations/region/clusters/clust/instances/inst?port=5348"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.ExecuteContext(ctx) }()
+
+	// Wait for the first successful startup check.
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		var err error
+		resp, err = tryDial("GET", "http://localhost:9199/startup")
+		if err != nil {
+			t.Fatalf("failed to dial startup endpoint: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want /startup status = 200, got = %v", resp.StatusCode)
+	}
+	// The server keeps running until the second success.
+	resp, err := http.Get("http://localhost:9199/startup")
+	if err != nil {
+		t.Fatalf("want server running after first success, got error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want /startup status = 200, got = %v", resp.StatusCode)
+	}
+
+	waitForHTTPServerStop(t, "localhost:9199")
+	// The Proxy keeps serving connections.
+	conn, err := net.Dial("tcp", "127.0.0.1:5348")
+	if err != nil {
+		t.Fatalf("want proxy still listening, got error = %v", err)
+	}
+	conn.Close()
+	select {
+	case err := <-errCh:
+		t.Fatalf("want proxy still running, got exit with error = %v", err)
+	default:
+	}
+}
+
+func TestHealthCheckOnStartupOnlyTimeout(t *testing.T) {
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--health-check", "--http-port", "9200",
+		"--health-check-on-startup-only", "--health-check-startup-successes", "100",
+		"--health-check-startup-timeout", "2s",
+		"projects/proj/locations/region/clusters/clust/instances/inst?port=5349"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.ExecuteContext(ctx)
+
+	if _, err := tryDial("GET", "http://localhost:9200/liveness"); err != nil {
+		t.Fatalf("failed to dial liveness endpoint: %v", err)
+	}
+	waitForHTTPServerStop(t, "localhost:9200")
+}
+
 func TestPProfServer(t *testing.T) {
 	c := NewCommand(WithDialer(&spyDialer{}))
 	c.SilenceUsage = true
@@ -1192,6 +2771,122 @@ func TestPProfServer(t *testing.T) {
 	}
 }
 
+func TestPProfServerAllowsLongProfiles(t *testing.T) {
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--debug", "--admin-port", "9201",
+		"projects/proj/locations/region/clusters/clust/instances/inst?port=5350"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.ExecuteContext(ctx)
+	if _, err := tryDial("GET", "http://localhost:9201/debug/pprof/"); err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+
+	for _, path := range []string{"/debug/pprof/profile", "/debug/pprof/trace"} {
+		// A profile that outlasts a write timeout is refused right away with
+		// a 400. Otherwise the request runs until the client gives up.
+		client := &http.Client{Timeout: time.Second}
+		resp, err := client.Get("http://localhost:9201" + path + "?seconds=60")
+		if err == nil {
+			resp.Body.Close()
+			t.Fatalf("%v: want profile to run past the client timeout, got status = %v",
+				path, resp.StatusCode)
+		}
+	}
+}
+
+func TestPIDFile(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "proxy.pid")
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--pid-file", pidFile,
+		"projects/proj/locations/region/clusters/clust/instances/inst?port=5330"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.ExecuteContext(ctx) }()
+
+	var (
+		b   []byte
+		err error
+	)
+	for i := 0; i < 50; i++ {
+		if b, err = os.ReadFile(pidFile); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("want PID file to be written, got = %v", err)
+	}
+	if want, got := strconv.Itoa(os.Getpid()), strings.TrimSpace(string(b)); got != want {
+		t.Fatalf("want PID = %v, got = %v", want, got)
+	}
+
+	cancel()
+	select {
+	case <-errCh:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for the proxy to shut down")
+	}
+	if _, err := os.Stat(pidFile); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want PID file removed on shutdown, got = %v", err)
+	}
+}
+
+func TestPIDFileWriteFailure(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "missing", "proxy.pid")
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--pid-file", pidFile,
+		"projects/proj/locations/region/clusters/clust/instances/inst?port=5331"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := c.ExecuteContext(ctx)
+	if err == nil || !strings.Contains(err.Error(), "failed to write PID file") {
+		t.Fatalf("want PID file error, got = %v", err)
+	}
+}
+
+func TestMaxStartupTime(t *testing.T) {
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	// The startup delay between instances keeps startup from completing.
+	c.SetArgs([]string{"--max-startup-time", "200ms", "--startup-delay", "1m",
+		"projects/proj/locations/region/clusters/clust/instances/inst1?port=5333",
+		"projects/proj/locations/region/clusters/clust/instances/inst2?port=5334"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := c.ExecuteContext(ctx)
+	if err == nil || !strings.Contains(err.Error(), "--max-startup-time") {
+		t.Fatalf("want max startup time error, got = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("want startup to time out after 200ms, took %v", elapsed)
+	}
+	// The listener bound before the timeout is closed.
+	var conn net.Conn
+	for i := 0; i < 10; i++ {
+		conn, err = net.Dial("tcp", "127.0.0.1:5333")
+		if err != nil {
+			return
+		}
+		conn.Close()
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("want listener closed after startup timed out")
+}
+
 func TestQuitQuitQuitHTTPPost(t *testing.T) {
 	c := NewCommand(WithDialer(&spyDialer{}))
 	c.SilenceUsage = true
@@ -1267,6 +2962,179 @@ func TestQuitQuitQuitGet(t *testing.T) {
 	}
 }
 
+func TestStartHTTPServerShutdownTimeout(t *testing.T) {
+	tcs := []struct {
+		desc            string
+		port            string
+		shutdownTimeout time.Duration
+		// wantServed reports whether the slow request completes.
+		wantServed bool
+	}{
+		{
+			desc:            "request finishes within the timeout",
+			port:            "9180",
+			shutdownTimeout: 2 * time.Second,
+			wantServed:      true,
+		},
+		{
+			desc:            "request outlasts the timeout",
+			port:            "9181",
+			shutdownTimeout: 50 * time.Millisecond,
+			wantServed:      false,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			started := make(chan struct{})
+			mux := http.NewServeMux()
+			mux.HandleFunc("/ready", func(http.ResponseWriter, *http.Request) {})
+			mux.HandleFunc("/slow", func(rw http.ResponseWriter, _ *http.Request) {
+				close(started)
+				time.Sleep(500 * time.Millisecond)
+				rw.WriteHeader(http.StatusOK)
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			stopped := make(chan struct{})
+			go func() {
+				startHTTPServer(ctx, log.NewStdLogger(io.Discard, io.Discard), "localhost:"+tc.port, mux,
+					time.Second, httpWriteTimeout, tc.shutdownTimeout, make(chan error, 1))
+				close(stopped)
+			}()
+			if _, err := tryDial("GET", "http://localhost:"+tc.port+"/ready"); err != nil {
+				t.Fatalf("failed to dial server: %v", err)
+			}
+
+			served := make(chan bool, 1)
+			go func() {
+				resp, err := http.Get("http://localhost:" + tc.port + "/slow")
+				if err == nil {
+					resp.Body.Close()
+				}
+				served <- err == nil && resp.StatusCode == http.StatusOK
+			}()
+			<-started
+			start := time.Now()
+			cancel()
+			<-stopped
+
+			if took := time.Since(start); took > tc.shutdownTimeout+250*time.Millisecond {
+				t.Errorf("want shutdown within %v, took %v", tc.shutdownTimeout, took)
+			}
+			if !tc.wantServed {
+				return
+			}
+			if !<-served {
+				t.Error("want in-flight request to finish before shutdown")
+			}
+		})
+	}
+}
+
+func TestWatchdogSendsKeepAlives(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		pings int
+	)
+	orig := sdNotify
+	sdNotify = func(_ bool, state string) (bool, error) {
+		if state == daemon.SdNotifyWatchdog {
+			mu.Lock()
+			pings++
+			mu.Unlock()
+		}
+		return true, nil
+	}
+	defer func() { sdNotify = orig }()
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return pings
+	}
+
+	// A 40ms watchdog is pinged every 20ms.
+	t.Setenv("WATCHDOG_USEC", "40000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	ctx, cancel := context.WithCancel(context.Background())
+	startWatchdog(ctx, log.NewStdLogger(io.Discard, io.Discard))
+
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	if got := count(); got < 3 {
+		t.Fatalf("want at least 3 keep-alives, got = %v", got)
+	}
+
+	// Give a keep-alive in flight time to land, then confirm they stop.
+	time.Sleep(50 * time.Millisecond)
+	stopped := count()
+	time.Sleep(100 * time.Millisecond)
+	if got := count(); got != stopped {
+		t.Fatalf("want keep-alives to stop after cancel, got %v more", got-stopped)
+	}
+}
+
+func TestWatchdogDisabledWithoutSystemd(t *testing.T) {
+	orig := sdNotify
+	sdNotify = func(bool, string) (bool, error) {
+		t.Error("want no notifications without a watchdog")
+		return false, nil
+	}
+	defer func() { sdNotify = orig }()
+
+	t.Setenv("WATCHDOG_USEC", "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startWatchdog(ctx, log.NewStdLogger(io.Discard, io.Discard))
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestQuitQuitQuitRespondsOnceToConcurrentRequests(t *testing.T) {
+	var shuttingDown atomic.Bool
+	shutdownCh := make(chan error, 1)
+	h := quitquitquit(&shuttingDown, shutdownCh)
+
+	const n = 10
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			h(rec, httptest.NewRequest(http.MethodPost, "/quitquitquit", nil))
+			codes <- rec.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	got := make(map[int]int)
+	for c := range codes {
+		got[c]++
+	}
+	want := map[int]int{http.StatusOK: 1, http.StatusServiceUnavailable: n - 1}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("response codes mismatch (-want +got):\n%v", diff)
+	}
+	if err := <-shutdownCh; !errors.Is(err, errQuitQuitQuit) {
+		t.Fatalf("want = %v, got = %v", errQuitQuitQuit, err)
+	}
+}
+
+func TestQuitQuitQuitAfterShutdownBegins(t *testing.T) {
+	var shuttingDown atomic.Bool
+	shuttingDown.Store(true)
+	h := quitquitquit(&shuttingDown, make(chan error))
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodPost, "/quitquitquit", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("want = %v, got = %v", http.StatusServiceUnavailable, rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "shutting down") {
+		t.Fatalf("want body to contain %q, got = %q", "shutting down", body)
+	}
+}
+
 type errorDialer struct {
 	spyDialer
 }
@@ -1305,3 +3173,113 @@ func TestQuitQuitQuitWithErrors(t *testing.T) {
 		t.Fatalf("want = %v, got = %v", errCloseFailed, got)
 	}
 }
+
+func TestReloadCredentialsEndpoint(t *testing.T) {
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--credentials-file", "/path/to/file", "--reload-credentials",
+		"--admin-port", "9195",
+		"projects/proj/locations/region/clusters/clust/instances/inst?port=5332"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.ExecuteContext(ctx)
+
+	resp, err := tryDial("GET", "http://localhost:9195/reload-credentials")
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a 400 status, got = %v", resp.StatusCode)
+	}
+	// A caller-provided dialer cannot be reloaded, so the reload fails.
+	resp, err = tryDial("POST", "http://localhost:9195/reload-credentials")
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected a 500 status, got = %v", resp.StatusCode)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent writers.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestQuietHealthChecks(t *testing.T) {
+	out := &syncBuffer{}
+	c := NewCommand(WithDialer(&spyDialer{}), WithLogger(log.NewStdLogger(out, out)))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--quiet-healthchecks",
+		"--health-check", "--http-port", "9197",
+		"--quitquitquit", "--admin-port", "9198",
+		"projects/proj/locations/region/clusters/clust/instances/inst?port=5336"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error)
+	go func() { errCh <- c.ExecuteContext(ctx) }()
+
+	if _, err := tryDial("GET", "http://localhost:9197/liveness"); err != nil {
+		t.Fatalf("failed to dial liveness endpoint: %v", err)
+	}
+	if _, err := tryDial("POST", "http://localhost:9198/quitquitquit"); err != nil {
+		t.Fatalf("failed to dial quitquitquit endpoint: %v", err)
+	}
+	<-errCh
+
+	got := out.String()
+	for _, msg := range []string{"Starting health check server", "Enabling quitquitquit endpoint"} {
+		if strings.Contains(got, msg) {
+			t.Errorf("want no %q message, got = %v", msg, got)
+		}
+	}
+	if want := "The proxy has started successfully"; !strings.Contains(got, want) {
+		t.Errorf("want %q message, got = %v", want, got)
+	}
+}
+
+func TestManualStartEndpoints(t *testing.T) {
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{"--manual-start", "--admin-port", "9194",
+		"projects/proj/locations/region/clusters/clust/instances/inst?port=5324"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.ExecuteContext(ctx)
+
+	for _, path := range []string{"/start", "/stop"} {
+		resp, err := tryDial("GET", "http://localhost:9194"+path)
+		if err != nil {
+			t.Fatalf("failed to dial endpoint: %v", err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected a 400 status, got = %v", resp.StatusCode)
+		}
+		resp, err = tryDial("POST", "http://localhost:9194"+path)
+		if err != nil {
+			t.Fatalf("failed to dial endpoint: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected a 200 status, got = %v", resp.StatusCode)
+		}
+	}
+}
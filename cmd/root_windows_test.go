@@ -34,3 +34,15 @@ func TestWindowsDoesNotSupportFUSE(t *testing.T) {
 		t.Fatal("want error != nil, got = nil")
 	}
 }
+
+func TestNewCommandWithNamedPipe(t *testing.T) {
+	c, err := invokeProxyCommand([]string{
+		"--named-pipe", "projects/proj/locations/region/clusters/clust/instances/inst",
+	})
+	if err != nil {
+		t.Fatalf("want error = nil, got = %v", err)
+	}
+	if !c.conf.NamedPipe {
+		t.Fatal("want NamedPipe = true, got = false")
+	}
+}
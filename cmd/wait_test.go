@@ -15,8 +15,12 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -66,3 +70,91 @@ func TestWaitCommandFails(t *testing.T) {
 		t.Fatal("wait should fail when endpoint does not respond")
 	}
 }
+
+func TestWaitCommandJSONOutput(t *testing.T) {
+	tcs := []struct {
+		desc       string
+		code       int
+		wantStatus string
+		wantErr    bool
+	}{
+		{desc: "ready", code: http.StatusOK, wantStatus: "ready"},
+		{desc: "timeout", code: http.StatusServiceUnavailable, wantStatus: "timeout", wantErr: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tc.code)
+			}))
+			defer s.Close()
+			host, port, err := net.SplitHostPort(s.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := NewCommand()
+			c.SilenceUsage = true
+			c.SilenceErrors = true
+			var out bytes.Buffer
+			c.SetOut(&out)
+			c.SetArgs([]string{
+				"wait", "--json",
+				"--http-address", host,
+				"--http-port", port,
+				"--max=500ms",
+			})
+			err = c.Execute()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("want error = %v, got = %v", tc.wantErr, err)
+			}
+
+			var got struct {
+				Status string `json:"status"`
+				Waited string `json:"waited"`
+			}
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode output %q: %v", out.String(), err)
+			}
+			if got.Status != tc.wantStatus {
+				t.Fatalf("want status = %v, got = %v", tc.wantStatus, got.Status)
+			}
+			if tc.wantStatus == "ready" {
+				if _, err := time.ParseDuration(got.Waited); err != nil {
+					t.Fatalf("want waited as a duration, got = %q", got.Waited)
+				}
+			}
+		})
+	}
+}
+
+func TestWaitCommandBacksOffUntilReady(t *testing.T) {
+	readyAt := time.Now().Add(300 * time.Millisecond)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if time.Now().Before(readyAt) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+	host, port, err := net.SplitHostPort(s.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = invokeProxyCommand([]string{
+		"wait",
+		"--http-address", host,
+		"--http-port", port,
+		"--max=3s",
+	})
+	if err != nil {
+		t.Fatalf("want error = nil, got = %v", err)
+	}
+	// The polling interval starts at 100ms, so the command should finish
+	// well before a fixed one second interval would allow.
+	if got := time.Since(start); got > time.Second {
+		t.Fatalf("want wait to finish shortly after ready, took %v", got)
+	}
+}
@@ -17,10 +17,14 @@
 package healthcheck
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/alloydb"
 	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/internal/proxy"
@@ -35,6 +39,27 @@ type Check struct {
 	stopped     chan struct{}
 	proxy       *proxy.Client
 	logger      alloydb.Logger
+
+	// checkMu protects background, checked, and checkErr.
+	checkMu sync.Mutex
+	// background reports whether StartChecks has been called, in which case
+	// HandleReadiness reports the cached result of the background checks.
+	background bool
+	// checked reports whether a background check has completed.
+	checked bool
+	// checkErr is the result of the most recent background check.
+	checkErr error
+
+	// startupMu protects startupOKs, startupWant, and startupDone.
+	startupMu sync.Mutex
+	// startupOKs is the number of times HandleStartup reported success.
+	startupOKs int
+	// startupWant is the number of successes after which startupDone is
+	// closed.
+	startupWant int
+	// startupDone is closed once HandleStartup reports success startupWant
+	// times. It is nil unless NotifyAfterStartup has been called.
+	startupDone chan struct{}
 }
 
 // NewCheck is the initializer for Check.
@@ -54,70 +79,299 @@ func (c *Check) NotifyStarted() {
 	c.startedOnce.Do(func() { close(c.started) })
 }
 
+// StartChecks runs CheckConnections every interval in the background and
+// caches the result for HandleReadiness, so readiness reflects the most
+// recent check without dialing any instances itself. The first check runs
+// immediately. The checks stop when ctx is done or the Check is notified that
+// the Proxy stopped.
+func (c *Check) StartChecks(ctx context.Context, interval time.Duration) {
+	c.checkMu.Lock()
+	c.background = true
+	c.checkMu.Unlock()
+
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			_, err := c.proxy.CheckConnections(ctx)
+			if err != nil {
+				c.logger.Errorf("[Health Check] Background check failed: %v", err)
+			}
+			c.checkMu.Lock()
+			c.checked, c.checkErr = true, err
+			c.checkMu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.stopped:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// cachedCheck returns the result of the most recent background check and
+// whether background checks are enabled.
+func (c *Check) cachedCheck() (bool, error) {
+	c.checkMu.Lock()
+	defer c.checkMu.Unlock()
+	if !c.background {
+		return false, nil
+	}
+	if !c.checked {
+		return true, errNotChecked
+	}
+	return true, c.checkErr
+}
+
 // NotifyStopped notifies the check that the proxy has initiated its shutdown
 // sequence.
 func (c *Check) NotifyStopped() {
 	c.stoppedOnce.Do(func() { close(c.stopped) })
 }
 
+// status is the body of a health check response.
+type status struct {
+	// Status is "ok" when the check passed, and "error" otherwise.
+	Status string `json:"status"`
+	// Error describes why the check failed.
+	Error string `json:"error,omitempty"`
+	// OpenConnections is the number of open client connections.
+	OpenConnections *uint64 `json:"openConnections,omitempty"`
+	// MaxConnections is the maximum number of allowed client connections.
+	MaxConnections uint64 `json:"maxConnections,omitempty"`
+	// UnreachableInstances lists the instances that failed the readiness
+	// probe.
+	UnreachableInstances []string `json:"unreachableInstances,omitempty"`
+}
+
+// writeStatus writes s as JSON when the client accepts JSON, and as text
+// otherwise. The first line of the text body is "ok" or the error, followed
+// by any details.
+func writeStatus(w http.ResponseWriter, req *http.Request, code int, s status) {
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(s)
+		return
+	}
+	var b strings.Builder
+	if s.Error != "" {
+		b.WriteString(s.Error)
+	} else {
+		b.WriteString(s.Status)
+	}
+	if s.OpenConnections != nil {
+		fmt.Fprintf(&b, "\nopen connections: %d", *s.OpenConnections)
+		if s.MaxConnections > 0 {
+			fmt.Fprintf(&b, " (max %d)", s.MaxConnections)
+		}
+	}
+	if len(s.UnreachableInstances) > 0 {
+		fmt.Fprintf(&b, "\nunreachable instances: %s", strings.Join(s.UnreachableInstances, ", "))
+	}
+	w.WriteHeader(code)
+	w.Write([]byte(b.String()))
+}
+
 // HandleStartup reports whether the Check has been notified of startup.
-func (c *Check) HandleStartup(w http.ResponseWriter, _ *http.Request) {
+func (c *Check) HandleStartup(w http.ResponseWriter, req *http.Request) {
 	select {
 	case <-c.started:
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("ok"))
+		writeStatus(w, req, http.StatusOK, status{Status: "ok"})
+		c.recordStartupOK()
 	default:
-		w.WriteHeader(http.StatusServiceUnavailable)
-		w.Write([]byte("error"))
+		writeStatus(w, req, http.StatusServiceUnavailable, status{Status: "error"})
+	}
+}
+
+// NotifyAfterStartup returns a channel that is closed once HandleStartup has
+// reported a successful startup n times. Values of n less than 1 are treated
+// as 1. It must be called before the Check serves requests.
+func (c *Check) NotifyAfterStartup(n int) <-chan struct{} {
+	c.startupMu.Lock()
+	defer c.startupMu.Unlock()
+	c.startupWant = max(n, 1)
+	c.startupDone = make(chan struct{})
+	return c.startupDone
+}
+
+// recordStartupOK counts a successful response from HandleStartup.
+func (c *Check) recordStartupOK() {
+	c.startupMu.Lock()
+	defer c.startupMu.Unlock()
+	c.startupOKs++
+	if c.startupDone != nil && c.startupOKs == c.startupWant {
+		close(c.startupDone)
 	}
 }
 
 var (
 	errNotStarted = errors.New("proxy is not started")
 	errStopped    = errors.New("proxy has stopped")
+	errNotChecked = errors.New("background health check has not completed")
 )
 
 // HandleReadiness ensures the Check has been notified of successful startup,
-// that the proxy has not reached maximum connections, and that the Proxy has
-// not started shutting down.
-func (c *Check) HandleReadiness(w http.ResponseWriter, _ *http.Request) {
+// that the proxy has not reached maximum connections (or the configured
+// percentage of maximum connections), and that the Proxy has
+// not started shutting down. When the Proxy is configured with a readiness TCP
+// probe, HandleReadiness also ensures each instance is reachable. When
+// StartChecks has been called, HandleReadiness instead reports the result of
+// the most recent background check. The response body reports the open
+// connection count and any unreachable instances.
+func (c *Check) HandleReadiness(w http.ResponseWriter, req *http.Request) {
+	fail := func(s status, err error) {
+		c.logger.Errorf("[Health Check] Readiness failed: %v", err)
+		s.Status = "error"
+		s.Error = err.Error()
+		writeStatus(w, req, http.StatusServiceUnavailable, s)
+	}
+
 	select {
 	case <-c.started:
 		// Proxy has started.
 	default:
-		c.logger.Errorf("[Health Check] Readiness failed: %v", errNotStarted)
-		w.WriteHeader(http.StatusServiceUnavailable)
-		w.Write([]byte(errNotStarted.Error()))
+		fail(status{}, errNotStarted)
 		return
 	}
 
 	select {
 	case <-c.stopped:
-		c.logger.Errorf("[Health Check] Readiness failed: %v", errStopped)
-		w.WriteHeader(http.StatusServiceUnavailable)
-		w.Write([]byte(errStopped.Error()))
+		fail(status{}, errStopped)
 		return
 	default:
 		// Proxy has not stopped.
 	}
 
-	if open, maxCount := c.proxy.ConnCount(); maxCount > 0 && open == maxCount {
-		err := fmt.Errorf("max connections reached (open = %v, max = %v)", open, maxCount)
-		c.logger.Errorf("[Health Check] Readiness failed: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
-		w.Write([]byte(err.Error()))
+	open, maxCount := c.proxy.ConnCount()
+	s := status{OpenConnections: &open, MaxConnections: maxCount}
+	if maxCount > 0 && open == maxCount {
+		fail(s, fmt.Errorf("max connections reached (open = %v, max = %v)", open, maxCount))
+		return
+	}
+	if limit := c.proxy.ReadinessConnLimit(); limit > 0 && open > limit {
+		fail(s, fmt.Errorf("open connections exceed readiness limit (open = %v, limit = %v, max = %v)", open, limit, maxCount))
+		return
+	}
+
+	background, err := c.cachedCheck()
+	if !background {
+		_, err = c.proxy.ProbeConnections(req.Context())
+	}
+	if err != nil {
+		var mErr proxy.MultiErr
+		if errors.As(err, &mErr) {
+			for _, e := range mErr {
+				var iErr *proxy.InstanceError
+				if errors.As(e, &iErr) {
+					s.UnreachableInstances = append(s.UnreachableInstances, iErr.Instance)
+				}
+			}
+		}
+		fail(s, err)
 		return
 	}
 
 	// No error cases apply, 200 status.
+	s.Status = "ok"
+	writeStatus(w, req, http.StatusOK, s)
+}
+
+// instanceHealth is the health of a single instance in the response of
+// HandleInstancesHealth.
+type instanceHealth struct {
+	// Instance is the short name of the instance.
+	Instance string `json:"instance"`
+	// LastSuccess is the time of the most recent successful dial.
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	// LastFailure is the time of the most recent failed dial.
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+	// LastError is the error of the most recent failed dial.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// HandleInstancesHealth reports, as JSON, the time of the most recent
+// successful and failed dial for each instance along with the most recent
+// dial error.
+func (c *Check) HandleInstancesHealth(w http.ResponseWriter, _ *http.Request) {
+	hs := []instanceHealth{}
+	for _, h := range c.proxy.InstanceHealth() {
+		ih := instanceHealth{Instance: h.Instance}
+		if !h.LastSuccess.IsZero() {
+			t := h.LastSuccess.UTC()
+			ih.LastSuccess = &t
+		}
+		if !h.LastFailure.IsZero() {
+			t := h.LastFailure.UTC()
+			ih.LastFailure = &t
+		}
+		if h.LastError != nil {
+			ih.LastError = h.LastError.Error()
+		}
+		hs = append(hs, ih)
+	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("ok"))
+	_ = json.NewEncoder(w).Encode(struct {
+		Instances []instanceHealth `json:"instances"`
+	}{hs})
+}
+
+// refreshHealth is the health of a single instance in the response of
+// HandleConnectorHealth.
+type refreshHealth struct {
+	// Instance is the short name of the instance.
+	Instance string `json:"instance"`
+	// LastSuccess is the time of the most recent successful refresh.
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	// LastFailure is the time of the most recent failed refresh.
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+	// LastError is the error of the most recent failed refresh.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// HandleConnectorHealth reports, as JSON, the time of the most recent
+// successful and failed certificate refresh for each instance along with the
+// most recent refresh error. It returns a 503 status when the most recent
+// refresh of any instance failed.
+func (c *Check) HandleConnectorHealth(w http.ResponseWriter, _ *http.Request) {
+	var (
+		code = http.StatusOK
+		st   = "ok"
+		hs   = []refreshHealth{}
+	)
+	for _, h := range c.proxy.RefreshHealth() {
+		rh := refreshHealth{Instance: h.Instance}
+		if !h.LastSuccess.IsZero() {
+			t := h.LastSuccess.UTC()
+			rh.LastSuccess = &t
+		}
+		if !h.LastFailure.IsZero() {
+			t := h.LastFailure.UTC()
+			rh.LastFailure = &t
+		}
+		if h.LastError != nil {
+			rh.LastError = h.LastError.Error()
+		}
+		if h.Failing() {
+			code, st = http.StatusServiceUnavailable, "degraded"
+		}
+		hs = append(hs, rh)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(struct {
+		Status    string          `json:"status"`
+		Instances []refreshHealth `json:"instances"`
+	}{st, hs})
 }
 
 // HandleLiveness indicates the process is up and responding to HTTP requests.
 // If this check fails (because it's not reachable), the process is in a bad
 // state and should be restarted.
-func (c *Check) HandleLiveness(w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("ok"))
+func (c *Check) HandleLiveness(w http.ResponseWriter, req *http.Request) {
+	writeStatus(w, req, http.StatusOK, status{Status: "ok"})
 }
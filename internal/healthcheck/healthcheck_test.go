@@ -16,6 +16,8 @@ package healthcheck_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -24,6 +26,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -137,6 +140,42 @@ func TestHandleStartupWhenNotified(t *testing.T) {
 	}
 }
 
+func TestNotifyAfterStartup(t *testing.T) {
+	p := newTestProxy(t)
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+	done := check.NotifyAfterStartup(2)
+	startup := func() {
+		check.HandleStartup(httptest.NewRecorder(), &http.Request{URL: &url.URL{}})
+	}
+	isDone := func() bool {
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// Failed startup checks are not counted.
+	startup()
+	check.NotifyStarted()
+	startup()
+	if isDone() {
+		t.Fatal("want not done after one successful startup check")
+	}
+	startup()
+	if !isDone() {
+		t.Fatal("want done after two successful startup checks")
+	}
+	// Further checks still succeed.
+	startup()
+}
+
 func TestHandleReadinessWhenNotNotified(t *testing.T) {
 	p := newTestProxy(t)
 	defer func() {
@@ -224,3 +263,442 @@ func TestHandleReadinessForMaxConns(t *testing.T) {
 		t.Fatalf("want max connections error, got = %v", string(body))
 	}
 }
+
+type errorDialer struct {
+	fakeDialer
+}
+
+func (*errorDialer) Dial(_ context.Context, _ string, _ ...alloydbconn.DialOption) (net.Conn, error) {
+	return nil, errors.New("errorDialer always errors")
+}
+
+func TestHandleReadinessWithTCPProbe(t *testing.T) {
+	tcs := []struct {
+		desc     string
+		dialer   alloydb.Dialer
+		wantCode int
+	}{
+		{
+			desc:     "instance is reachable",
+			dialer:   &fakeDialer{},
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:     "instance is unreachable",
+			dialer:   &errorDialer{},
+			wantCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := &proxy.Config{
+				Addr:              proxyHost,
+				Port:              proxyPort,
+				ReadinessTCPProbe: true,
+				Instances: []proxy.InstanceConnConfig{
+					{Name: "projects/proj/locations/region/clusters/clust/instances/inst"},
+				},
+			}
+			p, err := proxy.NewClient(context.Background(), tc.dialer, logger, c)
+			if err != nil {
+				t.Fatalf("proxy.NewClient: %v", err)
+			}
+			defer func() {
+				if err := p.Close(); err != nil {
+					t.Logf("failed to close proxy client: %v", err)
+				}
+			}()
+			check := healthcheck.NewCheck(p, logger)
+			check.NotifyStarted()
+
+			rec := httptest.NewRecorder()
+			check.HandleReadiness(rec, &http.Request{URL: &url.URL{}})
+
+			resp := rec.Result()
+			if got := resp.StatusCode; got != tc.wantCode {
+				t.Fatalf("want = %v, got = %v", tc.wantCode, got)
+			}
+		})
+	}
+}
+
+func TestHandleReadinessBody(t *testing.T) {
+	tcs := []struct {
+		desc     string
+		dialer   alloydb.Dialer
+		accept   string
+		wantCode int
+		wantBody string
+	}{
+		{
+			desc:     "healthy as text",
+			dialer:   &fakeDialer{},
+			wantCode: http.StatusOK,
+			wantBody: "ok\nopen connections: 0",
+		},
+		{
+			desc:     "healthy as JSON",
+			dialer:   &fakeDialer{},
+			accept:   "application/json",
+			wantCode: http.StatusOK,
+			wantBody: `{"status":"ok","openConnections":0}` + "\n",
+		},
+		{
+			desc:     "unreachable instance as text",
+			dialer:   &errorDialer{},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "[proj.region.clust.inst] probe failed: errorDialer always errors\n" +
+				"open connections: 0\n" +
+				"unreachable instances: proj.region.clust.inst",
+		},
+		{
+			desc:     "unreachable instance as JSON",
+			dialer:   &errorDialer{},
+			accept:   "application/json",
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"status":"error","error":"[proj.region.clust.inst] probe failed: errorDialer always errors",` +
+				`"openConnections":0,"unreachableInstances":["proj.region.clust.inst"]}` + "\n",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := &proxy.Config{
+				Addr:              proxyHost,
+				Port:              proxyPort,
+				ReadinessTCPProbe: true,
+				Instances: []proxy.InstanceConnConfig{
+					{Name: "projects/proj/locations/region/clusters/clust/instances/inst"},
+				},
+			}
+			p, err := proxy.NewClient(context.Background(), tc.dialer, logger, c)
+			if err != nil {
+				t.Fatalf("proxy.NewClient: %v", err)
+			}
+			defer func() {
+				if err := p.Close(); err != nil {
+					t.Logf("failed to close proxy client: %v", err)
+				}
+			}()
+			check := healthcheck.NewCheck(p, logger)
+			check.NotifyStarted()
+
+			rec := httptest.NewRecorder()
+			req := &http.Request{URL: &url.URL{}, Header: http.Header{}}
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			check.HandleReadiness(rec, req)
+
+			resp := rec.Result()
+			if got := resp.StatusCode; got != tc.wantCode {
+				t.Fatalf("want = %v, got = %v", tc.wantCode, got)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read response body: %v", err)
+			}
+			if got := string(body); got != tc.wantBody {
+				t.Fatalf("want = %q, got = %q", tc.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestHandleReadinessForMaxConnsPct(t *testing.T) {
+	c := &proxy.Config{
+		Addr:                       proxyHost,
+		Port:                       proxyPort,
+		MaxConnections:             4,
+		ReadinessMaxConnectionsPct: 50,
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+	}
+	p, err := proxy.NewClient(context.Background(), &fakeDialer{}, logger, c)
+	if err != nil {
+		t.Fatalf("proxy.NewClient: %v", err)
+	}
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+	go p.Serve(context.Background(), check.NotifyStarted)
+
+	waitForOpen := func(t *testing.T, want uint64) {
+		for i := 0; i < 10; i++ {
+			if open, _ := p.ConnCount(); open == want {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("failed to reach %v open connections", want)
+	}
+	readiness := func() int {
+		rec := httptest.NewRecorder()
+		check.HandleReadiness(rec, &http.Request{URL: &url.URL{}})
+		return rec.Result().StatusCode
+	}
+
+	// At the limit of 2 connections (50% of 4), the proxy is ready.
+	for i := 0; i < 2; i++ {
+		conn := dialTCP(t, proxyAddr())
+		defer conn.Close()
+	}
+	waitForOpen(t, 2)
+	if got, want := readiness(), http.StatusOK; got != want {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+
+	// Above the limit, the proxy is not ready.
+	conn := dialTCP(t, proxyAddr())
+	defer conn.Close()
+	waitForOpen(t, 3)
+	if got, want := readiness(), http.StatusServiceUnavailable; got != want {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+}
+
+// flakyDialer fails every Dial while fail is set.
+type flakyDialer struct {
+	fakeDialer
+	fail  atomic.Bool
+	dials atomic.Int64
+}
+
+func (d *flakyDialer) Dial(ctx context.Context, inst string, opts ...alloydbconn.DialOption) (net.Conn, error) {
+	d.dials.Add(1)
+	if d.fail.Load() {
+		return nil, errors.New("flakyDialer errors")
+	}
+	return d.fakeDialer.Dial(ctx, inst, opts...)
+}
+
+func TestHandleInstancesHealth(t *testing.T) {
+	d := &flakyDialer{}
+	p := newProxyWithParams(t, 0, d, []proxy.InstanceConnConfig{
+		{Name: "projects/proj/locations/region/clusters/clust/instances/inst"},
+	})
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+
+	type instance struct {
+		Instance    string     `json:"instance"`
+		LastSuccess *time.Time `json:"lastSuccess"`
+		LastFailure *time.Time `json:"lastFailure"`
+		LastError   string     `json:"lastError"`
+	}
+	health := func() instance {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		check.HandleInstancesHealth(rec, &http.Request{URL: &url.URL{}})
+		resp := rec.Result()
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("want = %v, got = %v", want, got)
+		}
+		var body struct {
+			Instances []instance `json:"instances"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+		if len(body.Instances) != 1 {
+			t.Fatalf("want 1 instance, got = %v", body.Instances)
+		}
+		return body.Instances[0]
+	}
+
+	got := health()
+	if got.Instance != "proj.region.clust.inst" {
+		t.Fatalf("want instance = proj.region.clust.inst, got = %v", got.Instance)
+	}
+	if got.LastSuccess != nil || got.LastFailure != nil || got.LastError != "" {
+		t.Fatalf("want no dials reported before dialing, got = %+v", got)
+	}
+
+	if _, err := p.CheckConnections(context.Background()); err != nil {
+		t.Fatalf("CheckConnections: %v", err)
+	}
+	got = health()
+	if got.LastSuccess == nil {
+		t.Fatal("want last success after successful dial, got none")
+	}
+	if got.LastFailure != nil || got.LastError != "" {
+		t.Fatalf("want no failure after successful dial, got = %+v", got)
+	}
+	firstSuccess := *got.LastSuccess
+
+	d.fail.Store(true)
+	if _, err := p.CheckConnections(context.Background()); err == nil {
+		t.Fatal("want CheckConnections error, got nil")
+	}
+	got = health()
+	if got.LastFailure == nil || got.LastFailure.Before(firstSuccess) {
+		t.Fatalf("want last failure after %v, got = %v", firstSuccess, got.LastFailure)
+	}
+	if got.LastError != "flakyDialer errors" {
+		t.Fatalf("want last error = %q, got = %q", "flakyDialer errors", got.LastError)
+	}
+	if got.LastSuccess == nil || !got.LastSuccess.Equal(firstSuccess) {
+		t.Fatalf("want last success unchanged = %v, got = %v", firstSuccess, got.LastSuccess)
+	}
+	failure := *got.LastFailure
+
+	d.fail.Store(false)
+	if _, err := p.CheckConnections(context.Background()); err != nil {
+		t.Fatalf("CheckConnections: %v", err)
+	}
+	got = health()
+	if got.LastSuccess == nil || got.LastSuccess.Before(failure) {
+		t.Fatalf("want last success after %v, got = %v", failure, got.LastSuccess)
+	}
+	if got.LastFailure == nil || !got.LastFailure.Equal(failure) {
+		t.Fatalf("want last failure unchanged = %v, got = %v", failure, got.LastFailure)
+	}
+}
+
+func TestHandleConnectorHealth(t *testing.T) {
+	p := newTestProxy(t)
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+
+	rec := httptest.NewRecorder()
+	check.HandleConnectorHealth(rec, &http.Request{URL: &url.URL{}})
+	resp := rec.Result()
+	// A client with a test dialer never refreshes, so nothing is failing.
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+	if got, want := resp.Header.Get("Content-Type"), "application/json"; got != want {
+		t.Fatalf("want Content-Type = %v, got = %v", want, got)
+	}
+	var body struct {
+		Status    string            `json:"status"`
+		Instances []json.RawMessage `json:"instances"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if body.Status != "ok" {
+		t.Fatalf("want status = ok, got = %v", body.Status)
+	}
+	if body.Instances == nil || len(body.Instances) != 0 {
+		t.Fatalf("want empty instances, got = %v", body.Instances)
+	}
+}
+
+func TestHandleReadinessWithBackgroundChecks(t *testing.T) {
+	d := &flakyDialer{}
+	p := newProxyWithParams(t, 0, d, []proxy.InstanceConnConfig{
+		{Name: "projects/proj/locations/region/clusters/clust/instances/inst"},
+	})
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+	check.NotifyStarted()
+
+	readiness := func() int {
+		rec := httptest.NewRecorder()
+		check.HandleReadiness(rec, &http.Request{URL: &url.URL{}})
+		return rec.Result().StatusCode
+	}
+	waitFor := func(want int) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if readiness() == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("want readiness status = %v, got = %v", want, readiness())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d.fail.Store(true)
+	check.StartChecks(ctx, 20*time.Millisecond)
+	waitFor(http.StatusServiceUnavailable)
+
+	// Readiness follows the cached result as it changes over time.
+	d.fail.Store(false)
+	waitFor(http.StatusOK)
+	d.fail.Store(true)
+	waitFor(http.StatusServiceUnavailable)
+	d.fail.Store(false)
+	waitFor(http.StatusOK)
+
+	// The background checks stop once the Proxy stops.
+	check.NotifyStopped()
+	time.Sleep(50 * time.Millisecond)
+	dials := d.dials.Load()
+	time.Sleep(100 * time.Millisecond)
+	if got := d.dials.Load(); got != dials {
+		t.Fatalf("want no dials after stopping, got = %v", got-dials)
+	}
+}
+
+// blockingDialer blocks every Dial until release is closed.
+type blockingDialer struct {
+	fakeDialer
+	release chan struct{}
+}
+
+func (d *blockingDialer) Dial(ctx context.Context, inst string, opts ...alloydbconn.DialOption) (net.Conn, error) {
+	<-d.release
+	return d.fakeDialer.Dial(ctx, inst, opts...)
+}
+
+func TestHandleReadinessBeforeFirstBackgroundCheck(t *testing.T) {
+	d := &blockingDialer{release: make(chan struct{})}
+	p := newProxyWithParams(t, 0, d, []proxy.InstanceConnConfig{
+		{Name: "projects/proj/locations/region/clusters/clust/instances/inst"},
+	})
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+	check.NotifyStarted()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	check.StartChecks(ctx, time.Hour)
+
+	rec := httptest.NewRecorder()
+	check.HandleReadiness(rec, &http.Request{URL: &url.URL{}})
+	resp := rec.Result()
+	if got, want := resp.StatusCode, http.StatusServiceUnavailable; got != want {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %v", err)
+	}
+	if !strings.Contains(string(body), "background health check has not completed") {
+		t.Fatalf("want pending check error, got = %q", string(body))
+	}
+
+	close(d.release)
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		check.HandleReadiness(rec, &http.Request{URL: &url.URL{}})
+		if rec.Result().StatusCode == http.StatusOK {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("want readiness to pass after the first background check")
+}
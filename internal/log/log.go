@@ -15,81 +15,262 @@
 package log
 
 import (
+	"fmt"
 	"io"
 	llog "log"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/alloydb"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// TimestampFormat controls how the time of each log message is written. The
+// zero value uses the Logger's default format.
+type TimestampFormat struct {
+	layout string
+	// epoch, when set, writes the time as an integer offset from the Unix
+	// epoch instead of using layout.
+	epoch func(time.Time) int64
+}
+
+// namedTimestampLayouts are the layouts that may be selected by name.
+var namedTimestampLayouts = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"iso8601":     "2006-01-02T15:04:05.000Z0700",
+}
+
+// namedTimestampEpochs are the integer epoch formats that may be selected by
+// name.
+var namedTimestampEpochs = map[string]func(time.Time) int64{
+	"unix":      time.Time.Unix,
+	"unixmilli": time.Time.UnixMilli,
+	"unixmicro": time.Time.UnixMicro,
+	"unixnano":  time.Time.UnixNano,
+}
+
+// ParseTimestampFormat returns the TimestampFormat named by s, one of
+// rfc3339, rfc3339nano, iso8601, unix, unixmilli, unixmicro, or unixnano.
+// Otherwise, s is used as a Go time layout (e.g., "2006-01-02 15:04:05") and
+// must include the date and the time to at least the minute. An empty s selects the default
+// format.
+func ParseTimestampFormat(s string) (TimestampFormat, error) {
+	if s == "" {
+		return TimestampFormat{}, nil
+	}
+	name := strings.ToLower(s)
+	if l, ok := namedTimestampLayouts[name]; ok {
+		return TimestampFormat{layout: l}, nil
+	}
+	if e, ok := namedTimestampEpochs[name]; ok {
+		return TimestampFormat{epoch: e}, nil
+	}
+	// Nearly any string is a valid Go layout, so require one that records
+	// the date and time. This catches misspelled names, which would
+	// otherwise be written verbatim or with a stray hour or day.
+	sample := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+	got, err := time.Parse(s, sample.Format(s))
+	if err != nil || !got.UTC().Truncate(time.Minute).Equal(sample.Truncate(time.Minute)) {
+		return TimestampFormat{}, fmt.Errorf(
+			"invalid timestamp format %q: want a known name or a Go time layout "+
+				"with the date and time", s,
+		)
+	}
+	return TimestampFormat{layout: s}, nil
+}
+
+// isDefault reports whether f uses the Logger's default format.
+func (f TimestampFormat) isDefault() bool {
+	return f.layout == "" && f.epoch == nil
+}
+
+// format returns t formatted according to f.
+func (f TimestampFormat) format(t time.Time) string {
+	if f.epoch != nil {
+		return strconv.FormatInt(f.epoch(t), 10)
+	}
+	return t.Format(f.layout)
+}
+
+// Option configures a Logger.
+type Option func(*options)
+
+type options struct {
+	timestampFormat TimestampFormat
+	source          bool
+}
+
+// WithTimestampFormat sets the format of the time written with each log
+// message.
+func WithTimestampFormat(f TimestampFormat) Option {
+	return func(o *options) {
+		o.timestampFormat = f
+	}
+}
+
+// WithSource adds the file and line of the code that logged each message.
+// Finding the caller has a cost, so it is best reserved for debugging.
+func WithSource() Option {
+	return func(o *options) {
+		o.source = true
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// loggerMethodPrefix is the prefix of the names of methods on this package's
+// Logger types.
+const loggerMethodPrefix = "github.com/GoogleCloudPlatform/alloydb-auth-proxy/internal/log.(*"
+
+// callerDepth returns the number of stack frames, starting with its caller,
+// that belong to this package's Loggers. Loggers wrap one another (e.g., to
+// redact secrets), so the number of frames between the code that logged a
+// message and the underlying log call varies.
+func callerDepth() int {
+	n := 0
+	for {
+		pc, _, _, ok := runtime.Caller(n + 1)
+		if !ok {
+			return n
+		}
+		f := runtime.FuncForPC(pc)
+		if f == nil || !strings.HasPrefix(f.Name(), loggerMethodPrefix) {
+			return n
+		}
+		n++
+	}
+}
+
+// timestampWriter prefixes each write with the current time. The standard
+// library's Logger makes a single write per message.
+type timestampWriter struct {
+	w io.Writer
+	f TimestampFormat
+}
+
+func (w *timestampWriter) Write(p []byte) (int, error) {
+	b := make([]byte, 0, len(p)+32)
+	b = append(b, w.f.format(time.Now())...)
+	b = append(b, ' ')
+	b = append(b, p...)
+	if _, err := w.w.Write(b); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
 // StdLogger is the standard logger that distinguishes between info and error
 // logs.
 type StdLogger struct {
 	infoLog  *llog.Logger
 	debugLog *llog.Logger
 	errLog   *llog.Logger
+	source   bool
 }
 
 // NewStdLogger create a Logger that uses out and err for informational and
 // error messages.
-func NewStdLogger(out, err io.Writer) alloydb.Logger {
+func NewStdLogger(out, err io.Writer, opts ...Option) alloydb.Logger {
+	o := newOptions(opts)
+	var flags int
+	if o.source {
+		flags |= llog.Lshortfile
+	}
+	newLog := func(w io.Writer) *llog.Logger {
+		if o.timestampFormat.isDefault() {
+			return llog.New(w, "", flags|llog.LstdFlags)
+		}
+		return llog.New(&timestampWriter{w: w, f: o.timestampFormat}, "", flags)
+	}
 	return &StdLogger{
-		infoLog:  llog.New(out, "", llog.LstdFlags),
-		debugLog: llog.New(out, "", llog.LstdFlags),
-		errLog:   llog.New(err, "", llog.LstdFlags),
+		infoLog:  newLog(out),
+		debugLog: newLog(out),
+		errLog:   newLog(err),
+		source:   o.source,
 	}
 }
 
+// output writes a message to lg, skipping this package's frames when
+// reporting the source of the message.
+func (l *StdLogger) output(lg *llog.Logger, format string, v ...interface{}) {
+	depth := 2
+	if l.source {
+		depth = callerDepth() + 1
+	}
+	_ = lg.Output(depth, fmt.Sprintf(format, v...))
+}
+
 // Infof logs informational messages.
 func (l *StdLogger) Infof(format string, v ...interface{}) {
-	l.infoLog.Printf(format, v...)
+	l.output(l.infoLog, format, v...)
 }
 
 // Errorf logs error messages.
 func (l *StdLogger) Errorf(format string, v ...interface{}) {
-	l.errLog.Printf(format, v...)
+	l.output(l.errLog, format, v...)
 }
 
 // Debugf logs debug messages.
 func (l *StdLogger) Debugf(format string, v ...interface{}) {
-	l.debugLog.Printf(format, v...)
+	l.output(l.debugLog, format, v...)
 }
 
 // StructuredLogger writes log messages in JSON.
 type StructuredLogger struct {
 	logger *zap.SugaredLogger
+	source bool
+}
+
+// sugar returns the logger to write a message with, skipping this package's
+// frames when reporting the source of the message.
+func (l *StructuredLogger) sugar() *zap.SugaredLogger {
+	if !l.source {
+		return l.logger
+	}
+	// zap reports the logging method that called sugar, so skip every
+	// frame in this package other than sugar itself.
+	return l.logger.WithOptions(zap.AddCallerSkip(callerDepth() - 1))
 }
 
 // Infof logs informational messages.
 func (l *StructuredLogger) Infof(format string, v ...interface{}) {
-	l.logger.Infof(format, v...)
+	l.sugar().Infof(format, v...)
 }
 
 // Errorf logs error messages.
 func (l *StructuredLogger) Errorf(format string, v ...interface{}) {
-	l.logger.Errorf(format, v...)
+	l.sugar().Errorf(format, v...)
 }
 
 // Debugf logs debug messages.
 func (l *StructuredLogger) Debugf(format string, v ...interface{}) {
-	l.logger.Debugf(format, v...)
+	l.sugar().Debugf(format, v...)
 }
 
-// NewStructuredLogger creates a Logger that logs messages using JSON.
-func NewStructuredLogger(quiet bool) (alloydb.Logger, func() error) {
-	// Configure structured logs to adhere to LogEntry format
-	// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
-	c := zap.NewProductionEncoderConfig()
-	c.LevelKey = "severity"
-	c.MessageKey = "message"
-	c.TimeKey = "timestamp"
-	c.EncodeLevel = zapcore.CapitalLevelEncoder
-	c.EncodeTime = zapcore.ISO8601TimeEncoder
-
-	enc := zapcore.NewJSONEncoder(c)
+// With returns a Logger that adds key and value as a field to every message.
+func (l *StructuredLogger) With(key, value string) alloydb.Logger {
+	return &StructuredLogger{logger: l.logger.With(key, value), source: l.source}
+}
 
+// NewStructuredLogger creates a Logger that logs messages using JSON. Each
+// entry in labels is added as a field to every message.
+func NewStructuredLogger(quiet bool, labels map[string]string, opts ...Option) (alloydb.Logger, func() error) {
 	var syncer zapcore.WriteSyncer
 	// quiet disables writing to the info log
 	if quiet {
@@ -97,18 +278,275 @@ func NewStructuredLogger(quiet bool) (alloydb.Logger, func() error) {
 	} else {
 		syncer = zapcore.Lock(os.Stdout)
 	}
+	return newStructuredLogger(syncer, zapcore.Lock(os.Stderr), labels, newOptions(opts))
+}
+
+// newStructuredLogger creates a Logger that writes JSON informational
+// messages to out and error messages to errOut.
+func newStructuredLogger(out, errOut zapcore.WriteSyncer, labels map[string]string, o options) (alloydb.Logger, func() error) {
+	enc := zapcore.NewJSONEncoder(encoderConfig(o))
 	core := zapcore.NewTee(
-		zapcore.NewCore(enc, syncer, zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+		zapcore.NewCore(enc, out, zap.LevelEnablerFunc(func(l zapcore.Level) bool {
 			// Anything below error, goes to the info log.
 			return l < zapcore.ErrorLevel
 		})),
-		zapcore.NewCore(enc, zapcore.Lock(os.Stderr), zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+		zapcore.NewCore(enc, errOut, zap.LevelEnablerFunc(func(l zapcore.Level) bool {
 			// Anything at error or higher goes to the error log.
 			return l >= zapcore.ErrorLevel
 		})),
 	)
+	var fields []zap.Field
+	for k, v := range labels {
+		fields = append(fields, zap.String(k, v))
+	}
+	var zopts []zap.Option
+	if o.source {
+		zopts = append(zopts, zap.AddCaller())
+	}
 	l := &StructuredLogger{
-		logger: zap.New(core).Sugar(),
+		logger: zap.New(core, zopts...).With(fields...).Sugar(),
+		source: o.source,
 	}
 	return l, l.logger.Sync
 }
+
+// encoderConfig returns the configuration for structured logs.
+func encoderConfig(o options) zapcore.EncoderConfig {
+	// Configure structured logs to adhere to LogEntry format
+	// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
+	c := zap.NewProductionEncoderConfig()
+	c.LevelKey = "severity"
+	c.MessageKey = "message"
+	c.TimeKey = "timestamp"
+	c.CallerKey = "sourceLocation"
+	c.EncodeLevel = zapcore.CapitalLevelEncoder
+	c.EncodeTime = zapcore.ISO8601TimeEncoder
+	if f := o.timestampFormat; !f.isDefault() {
+		c.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			if f.epoch != nil {
+				enc.AppendInt64(f.epoch(t))
+				return
+			}
+			enc.AppendString(t.Format(f.layout))
+		}
+	}
+	return c
+}
+
+// LogfmtLogger writes log messages as lines of space-separated key=value
+// pairs.
+type LogfmtLogger struct {
+	// mu serializes writes from this Logger and those derived from it with
+	// With.
+	mu     *sync.Mutex
+	out    io.Writer
+	errOut io.Writer
+	// fields are the encoded key=value pairs added to every message.
+	fields []byte
+	opts   options
+}
+
+// NewLogfmtLogger creates a Logger that writes logfmt informational messages
+// to out and error messages to err. Each entry in labels is added as a field
+// to every message.
+func NewLogfmtLogger(out, err io.Writer, labels map[string]string, opts ...Option) alloydb.Logger {
+	l := &LogfmtLogger{
+		mu:     &sync.Mutex{},
+		out:    out,
+		errOut: err,
+		opts:   newOptions(opts),
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		l.fields = appendLogfmtField(l.fields, k, labels[k])
+	}
+	return l
+}
+
+// appendLogfmtField appends key=value to b, quoting value when needed.
+func appendLogfmtField(b []byte, key, value string) []byte {
+	b = append(b, ' ')
+	b = append(b, key...)
+	b = append(b, '=')
+	if value == "" || strings.ContainsAny(value, " =\"\\") || strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		return strconv.AppendQuote(b, value)
+	}
+	return append(b, value...)
+}
+
+// output writes a message at level to w.
+func (l *LogfmtLogger) output(w io.Writer, level, format string, v ...interface{}) {
+	var ts string
+	if f := l.opts.timestampFormat; f.isDefault() {
+		ts = time.Now().Format(time.RFC3339)
+	} else {
+		ts = f.format(time.Now())
+	}
+	b := make([]byte, 0, 128)
+	b = append(b, "time="...)
+	b = append(b, ts...)
+	b = appendLogfmtField(b, "level", level)
+	b = appendLogfmtField(b, "msg", fmt.Sprintf(format, v...))
+	if l.opts.source {
+		// Skip this package's frames when reporting the source.
+		if _, file, line, ok := runtime.Caller(callerDepth()); ok {
+			b = appendLogfmtField(b, "source", filepath.Base(file)+":"+strconv.Itoa(line))
+		}
+	}
+	b = append(b, l.fields...)
+	b = append(b, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = w.Write(b)
+}
+
+// Infof logs informational messages.
+func (l *LogfmtLogger) Infof(format string, v ...interface{}) {
+	l.output(l.out, "info", format, v...)
+}
+
+// Errorf logs error messages.
+func (l *LogfmtLogger) Errorf(format string, v ...interface{}) {
+	l.output(l.errOut, "error", format, v...)
+}
+
+// Debugf logs debug messages.
+func (l *LogfmtLogger) Debugf(format string, v ...interface{}) {
+	l.output(l.out, "debug", format, v...)
+}
+
+// With returns a Logger that adds key and value as a field to every message.
+func (l *LogfmtLogger) With(key, value string) alloydb.Logger {
+	nl := *l
+	nl.fields = appendLogfmtField(append([]byte(nil), l.fields...), key, value)
+	return &nl
+}
+
+// redacted replaces secret values in log messages.
+const redacted = "<redacted>"
+
+var (
+	bearerRE     = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+	privateKeyRE = regexp.MustCompile(`("private_key(?:_id)?"\s*:\s*")[^"]*(")`)
+)
+
+// fieldLogger is implemented by loggers that support structured fields.
+type fieldLogger interface {
+	With(key, value string) alloydb.Logger
+}
+
+// redactingLogger masks secrets in messages before passing them to the
+// underlying Logger.
+type redactingLogger struct {
+	logger  alloydb.Logger
+	secrets []string
+}
+
+// NewRedactingLogger wraps l so that every occurrence of secrets, bearer
+// tokens, and private keys from credentials JSON are masked before being
+// logged. Empty secrets are ignored.
+func NewRedactingLogger(l alloydb.Logger, secrets ...string) alloydb.Logger {
+	r := &redactingLogger{logger: l}
+	for _, s := range secrets {
+		if s != "" {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	// Preserve support for structured fields when the wrapped Logger has it.
+	if _, ok := l.(fieldLogger); ok {
+		return &redactingFieldLogger{r}
+	}
+	return r
+}
+
+func (l *redactingLogger) redact(format string, v ...interface{}) string {
+	msg := fmt.Sprintf(format, v...)
+	for _, s := range l.secrets {
+		msg = strings.ReplaceAll(msg, s, redacted)
+	}
+	msg = bearerRE.ReplaceAllString(msg, "${1}"+redacted)
+	return privateKeyRE.ReplaceAllString(msg, "${1}"+redacted+"${2}")
+}
+
+// Infof logs informational messages.
+func (l *redactingLogger) Infof(format string, v ...interface{}) {
+	l.logger.Infof("%s", l.redact(format, v...))
+}
+
+// Errorf logs error messages.
+func (l *redactingLogger) Errorf(format string, v ...interface{}) {
+	l.logger.Errorf("%s", l.redact(format, v...))
+}
+
+// Debugf logs debug messages.
+func (l *redactingLogger) Debugf(format string, v ...interface{}) {
+	l.logger.Debugf("%s", l.redact(format, v...))
+}
+
+// redactingFieldLogger is a redactingLogger for Loggers that support
+// structured fields.
+type redactingFieldLogger struct {
+	*redactingLogger
+}
+
+// With returns a Logger that adds key and value as a field to every message.
+func (l *redactingFieldLogger) With(key, value string) alloydb.Logger {
+	fl := l.logger.(fieldLogger)
+	return &redactingFieldLogger{&redactingLogger{
+		logger:  fl.With(key, value),
+		secrets: l.secrets,
+	}}
+}
+
+// prefixLogger adds a prefix to every message.
+type prefixLogger struct {
+	logger alloydb.Logger
+	prefix string
+}
+
+// NewPrefixLogger wraps l so that prefix is added to every message. Like the
+// other Loggers in this package, it is skipped when finding the source of a
+// message.
+func NewPrefixLogger(l alloydb.Logger, prefix string) alloydb.Logger {
+	return &prefixLogger{logger: l, prefix: prefix}
+}
+
+// Infof logs informational messages with the prefix.
+func (l *prefixLogger) Infof(format string, v ...interface{}) {
+	l.logger.Infof(l.prefix+format, v...)
+}
+
+// Errorf logs error messages with the prefix.
+func (l *prefixLogger) Errorf(format string, v ...interface{}) {
+	l.logger.Errorf(l.prefix+format, v...)
+}
+
+// Debugf logs debug messages with the prefix.
+func (l *prefixLogger) Debugf(format string, v ...interface{}) {
+	l.logger.Debugf(l.prefix+format, v...)
+}
+
+// errorLogger discards all but error messages.
+type errorLogger struct {
+	logger alloydb.Logger
+}
+
+// NewErrorLogger wraps l so that only error messages are logged.
+func NewErrorLogger(l alloydb.Logger) alloydb.Logger {
+	return &errorLogger{logger: l}
+}
+
+// Infof discards informational messages.
+func (*errorLogger) Infof(string, ...interface{}) {}
+
+// Errorf logs error messages.
+func (l *errorLogger) Errorf(format string, v ...interface{}) {
+	l.logger.Errorf(format, v...)
+}
+
+// Debugf discards debug messages.
+func (*errorLogger) Debugf(string, ...interface{}) {}
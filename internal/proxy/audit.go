@@ -0,0 +1,116 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// auditConnect is the event recorded when a connection to an instance
+	// is established.
+	auditConnect = "connect"
+	// auditClose is the event recorded when a connection is closed.
+	auditClose = "close"
+)
+
+// auditRecord is a single line of the connection audit file.
+type auditRecord struct {
+	Time         string `json:"time"`
+	Event        string `json:"event"`
+	Instance     string `json:"instance"`
+	ConnectionID string `json:"connectionId"`
+	RemoteAddr   string `json:"remoteAddr"`
+	// BytesSent and BytesReceived are the bytes copied from the client to
+	// the instance and from the instance to the client. They are only set
+	// for close events.
+	BytesSent     *uint64 `json:"bytesSent,omitempty"`
+	BytesReceived *uint64 `json:"bytesReceived,omitempty"`
+}
+
+// auditLog appends a JSON line to a file for each connection event. Each
+// record is written with a single write to a file opened with O_APPEND, so
+// records are never interleaved, even with other writers to the same file.
+type auditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// newAuditLog opens path for appending, creating it if necessary.
+func newAuditLog(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{f: f}, nil
+}
+
+// write appends r to the audit file. Records written after close are
+// dropped.
+func (a *auditLog) write(r auditRecord) error {
+	if a == nil {
+		return nil
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.f.Write(append(b, '\n'))
+	if errors.Is(err, os.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// close closes the audit file.
+func (a *auditLog) close() error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	err := a.f.Close()
+	if errors.Is(err, os.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// auditConn records a connection event for cc to the audit log, if any.
+// sent and received are only recorded for close events.
+func (c *Client) auditConn(cc *clientConn, event, remoteAddr string, sent, received uint64) {
+	if c.audit == nil {
+		return
+	}
+	r := auditRecord{
+		Time:         time.Now().UTC().Format(time.RFC3339Nano),
+		Event:        event,
+		Instance:     cc.inst,
+		ConnectionID: cc.id,
+		RemoteAddr:   remoteAddr,
+	}
+	if event == auditClose {
+		r.BytesSent, r.BytesReceived = &sent, &received
+	}
+	if err := c.audit.write(r); err != nil {
+		cc.logger.Errorf("failed to write connection audit record: %v", err)
+	}
+}
@@ -55,9 +55,15 @@ IAM permissions.
 
 The proxy will create a directory with the instance short name, and create a
 socket inside that directory with the special Postgres name: .s.PGSQL.5432.
+If the proxy is started with --fuse-socket-suffix, the socket name uses that
+suffix instead and clients must connect with the matching port, e.g.,
+port=6432 for .s.PGSQL.6432.
 
 Listing the contents of this directory will show all instances with active
 connections.
+
+Looking up a socket path (e.g., with stat) does not connect to the instance.
+Only connecting to the socket does.
 `
 
 // Getattr implements fs.NodeGetattrer and indicates that this file is a regular
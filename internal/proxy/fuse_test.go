@@ -44,7 +44,12 @@ func randTmpDir(t interface {
 // proxy.Client and starts it. The returned cleanup function is also a
 // convenience. Callers may choose to ignore it and manually close the client.
 func newTestClient(t *testing.T, d alloydb.Dialer, fuseDir, fuseTempDir string) (*proxy.Client, chan error, func()) {
-	conf := &proxy.Config{FUSEDir: fuseDir, FUSETempDir: fuseTempDir}
+	return newTestClientWithConfig(t, d, &proxy.Config{FUSEDir: fuseDir, FUSETempDir: fuseTempDir})
+}
+
+// newTestClientWithConfig is like newTestClient but uses the provided
+// configuration.
+func newTestClientWithConfig(t *testing.T, d alloydb.Dialer, conf *proxy.Config) (*proxy.Client, chan error, func()) {
 	c, err := proxy.NewClient(context.Background(), d, testLogger, conf)
 	if err != nil {
 		t.Fatalf("want error = nil, got = %v", err)
@@ -194,6 +199,35 @@ func TestFUSEDialInstance(t *testing.T) {
 	}
 }
 
+func TestFUSEDialInstanceWithSocketSuffix(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping fuse tests in short mode.")
+	}
+	fuseDir := randTmpDir(t)
+	d := &fakeDialer{}
+	_, _, cleanup := newTestClientWithConfig(t, d, &proxy.Config{
+		FUSEDir:          fuseDir,
+		FUSETempDir:      randTmpDir(t),
+		FUSESocketSuffix: "6432",
+	})
+	defer cleanup()
+
+	conn := tryDialUnix(t, filepath.Join(fuseDir, "proj.region.cluster.instance", ".s.PGSQL.6432"))
+	defer conn.Close()
+
+	var got []string
+	for i := 0; i < 10; i++ {
+		got = d.dialedInstances()
+		if len(got) == 1 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if len(got) != 1 {
+		t.Fatalf("dialed instances len: want = 1, got = %v", got)
+	}
+}
+
 func TestFUSEAcceptErrorReturnedFromServe(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping fuse tests in short mode.")
@@ -276,6 +310,71 @@ func TestFUSEReadDir(t *testing.T) {
 	}
 }
 
+func TestFUSENoAutoconnectSkipsLookups(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping fuse tests in short mode.")
+	}
+	fuseDir := randTmpDir(t)
+	d := &fakeDialer{}
+	c, _, cleanup := newTestClientWithConfig(t, d, &proxy.Config{
+		FUSEDir:           fuseDir,
+		FUSETempDir:       randTmpDir(t),
+		FUSENoAutoconnect: true,
+	})
+	defer cleanup()
+
+	socketPath := postgresSocketPath(fuseDir, "proj.region.cluster.instance")
+	if _, err := os.Stat(socketPath); err != nil {
+		t.Fatalf("os.Stat(): %v", err)
+	}
+	n, err := c.CheckConnections(context.Background())
+	if err != nil {
+		t.Fatalf("CheckConnections(): %v", err)
+	}
+	if n != 0 || d.dialAttempts() != 0 {
+		t.Fatalf("want no instances checked after stat, got checked = %v, dials = %v",
+			n, d.dialAttempts())
+	}
+
+	conn := tryDialUnix(t, socketPath)
+	defer conn.Close()
+	for i := 0; i < 10 && d.dialAttempts() == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if n, err := c.CheckConnections(context.Background()); err != nil || n != 1 {
+		t.Fatalf("want 1 instance checked after connecting, got = %v (err = %v)", n, err)
+	}
+}
+
+func TestFUSEMaxInstances(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping fuse tests in short mode.")
+	}
+	fuseDir := randTmpDir(t)
+	d := &fakeDialer{}
+	_, _, cleanup := newTestClientWithConfig(t, d, &proxy.Config{
+		FUSEDir:          fuseDir,
+		FUSETempDir:      randTmpDir(t),
+		FUSEMaxInstances: 1,
+	})
+	defer cleanup()
+
+	conn := tryDialUnix(t, postgresSocketPath(fuseDir, "proj.region.cluster.instance1"))
+	defer conn.Close()
+
+	_, dialErr := net.Dial("unix", postgresSocketPath(fuseDir, "proj.region.cluster.instance2"))
+	if dialErr == nil {
+		t.Fatal("net.Dial() should fail when max FUSE instances is reached")
+	}
+
+	for i := 0; i < 10 && d.dialAttempts() == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got := d.dialedInstances(); len(got) != 1 {
+		t.Fatalf("dialed instances: want 1, got = %v", got)
+	}
+}
+
 func TestFUSEWithBadInstanceName(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping fuse tests in short mode.")
@@ -0,0 +1,144 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const (
+	// reasonMaxConnections indicates a connection was refused because the
+	// Client reached MaxConnections.
+	reasonMaxConnections = "max_connections"
+	// reasonRateLimit indicates a connection was refused because the Client
+	// exceeded NewConnectionRate.
+	reasonRateLimit = "rate_limit"
+
+	// resultSuccess indicates a certificate refresh succeeded.
+	resultSuccess = "success"
+	// resultFailure indicates a certificate refresh failed.
+	resultFailure = "failure"
+)
+
+var (
+	keyProject, _  = tag.NewKey("alloydb_project")
+	keyRegion, _   = tag.NewKey("alloydb_region")
+	keyCluster, _  = tag.NewKey("alloydb_cluster")
+	keyInstance, _ = tag.NewKey("alloydb_instance")
+	keyReason, _   = tag.NewKey("reason")
+	keyResult, _   = tag.NewKey("result")
+
+	mConnectionsRefused = stats.Int64(
+		"alloydbconn/connections_refused",
+		"A connection refused by the Proxy before dialing an AlloyDB instance",
+		stats.UnitDimensionless,
+	)
+
+	connectionsRefusedView = &view.View{
+		Name:        "alloydbconn/connections_refused",
+		Measure:     mConnectionsRefused,
+		Description: "The number of connections refused by the Proxy",
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{keyProject, keyRegion, keyCluster, keyInstance, keyReason},
+	}
+
+	mDrainingConnections = stats.Int64(
+		"alloydbconn/draining_connections",
+		"The number of open connections while the Proxy waits for them to close on shutdown",
+		stats.UnitDimensionless,
+	)
+
+	drainingConnectionsView = &view.View{
+		Name:        "alloydbconn/draining_connections",
+		Measure:     mDrainingConnections,
+		Description: "The number of open connections while the Proxy shuts down",
+		Aggregation: view.LastValue(),
+	}
+
+	mCertRefresh = stats.Int64(
+		"alloydbconn/cert_refresh",
+		"A refresh of an instance's ephemeral certificate by the connector",
+		stats.UnitDimensionless,
+	)
+
+	certRefreshView = &view.View{
+		Name:        "alloydbconn/cert_refresh",
+		Measure:     mCertRefresh,
+		Description: "The number of certificate refreshes by result",
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{keyProject, keyRegion, keyCluster, keyInstance, keyResult},
+	}
+
+	registerOnce sync.Once
+	registerErr  error
+)
+
+// InitMetrics registers all views of the Proxy once. Without registering
+// views, metrics will not be reported.
+func InitMetrics() error {
+	registerOnce.Do(func() {
+		if rErr := view.Register(
+			connectionsRefusedView,
+			drainingConnectionsView,
+			certRefreshView,
+		); rErr != nil {
+			registerErr = fmt.Errorf("failed to initialize metrics: %v", rErr)
+		}
+	})
+	return registerErr
+}
+
+// instanceTags returns the tags identifying an instance by its project,
+// region, cluster, and short name. Instance URIs that do not parse (e.g.,
+// with SkipURIValidation) are tagged by short name only.
+func instanceTags(inst, instShort string) []tag.Mutator {
+	ts := []tag.Mutator{tag.Upsert(keyInstance, instShort)}
+	p, r, c, _, err := ParseInstanceURI(inst)
+	if err != nil {
+		return ts
+	}
+	return append(ts,
+		tag.Upsert(keyProject, p),
+		tag.Upsert(keyRegion, r),
+		tag.Upsert(keyCluster, c),
+	)
+}
+
+// recordConnectionRefused reports a connection refused for the provided
+// reason.
+func recordConnectionRefused(ctx context.Context, inst, instShort, reason string) {
+	// tag.New errors only if the tag keys are invalid. Since the keys are
+	// defined in this package, the error can be ignored.
+	ctx, _ = tag.New(ctx, append(instanceTags(inst, instShort), tag.Upsert(keyReason, reason))...)
+	stats.Record(ctx, mConnectionsRefused.M(1))
+}
+
+// recordDrainingConnections reports the number of connections still open
+// while the Client waits for them to close.
+func recordDrainingConnections(ctx context.Context, open uint64) {
+	stats.Record(ctx, mDrainingConnections.M(int64(open)))
+}
+
+// recordCertRefresh reports a certificate refresh with the provided result.
+func recordCertRefresh(ctx context.Context, inst, instShort, result string) {
+	ctx, _ = tag.New(ctx, append(instanceTags(inst, instShort), tag.Upsert(keyResult, result))...)
+	stats.Record(ctx, mCertRefresh.M(1))
+}
@@ -67,6 +67,80 @@ func TestUnixSocketDir(t *testing.T) {
 	}
 }
 
+func TestParseInstanceURI(t *testing.T) {
+	valid := []struct {
+		desc        string
+		in          string
+		wantProject string
+	}{
+		{desc: "project", in: "projects/my-project/locations/reg/clusters/clust/instances/inst", wantProject: "my-project"},
+		{desc: "project with digits", in: "projects/proj-123/locations/reg/clusters/clust/instances/inst", wantProject: "proj-123"},
+		{desc: "uppercase project", in: "projects/PROJ/locations/reg/clusters/clust/instances/inst", wantProject: "PROJ"},
+		{desc: "domain-scoped project", in: "projects/google.com:proj/locations/reg/clusters/clust/instances/inst", wantProject: "google.com:proj"},
+		{desc: "domain with subdomains", in: "projects/corp.example.co.uk:proj/locations/reg/clusters/clust/instances/inst", wantProject: "corp.example.co.uk:proj"},
+		{desc: "domain with hyphens and digits", in: "projects/my-corp2.example.com:my-proj-1/locations/reg/clusters/clust/instances/inst", wantProject: "my-corp2.example.com:my-proj-1"},
+		{desc: "uppercase domain", in: "projects/GOOGLE.COM:PROJ/locations/reg/clusters/clust/instances/inst", wantProject: "GOOGLE.COM:PROJ"},
+	}
+	for _, tc := range valid {
+		t.Run(tc.desc, func(t *testing.T) {
+			p, r, c, i, err := ParseInstanceURI(tc.in)
+			if err != nil {
+				t.Fatalf("want error = nil, got = %v", err)
+			}
+			if p != tc.wantProject || r != "reg" || c != "clust" || i != "inst" {
+				t.Fatalf("want = %v reg clust inst, got = %v %v %v %v", tc.wantProject, p, r, c, i)
+			}
+		})
+	}
+
+	invalid := []struct {
+		desc string
+		in   string
+	}{
+		{desc: "multiple colons", in: "projects/google.com:bad:PROJECT/locations/reg/clusters/clust/instances/inst"},
+		{desc: "domain without a dot", in: "projects/google:proj/locations/reg/clusters/clust/instances/inst"},
+		{desc: "empty domain", in: "projects/:proj/locations/reg/clusters/clust/instances/inst"},
+		{desc: "empty project with domain", in: "projects/google.com:/locations/reg/clusters/clust/instances/inst"},
+		{desc: "domain label ending in a hyphen", in: "projects/google-.com:proj/locations/reg/clusters/clust/instances/inst"},
+		{desc: "domain with an empty label", in: "projects/google..com:proj/locations/reg/clusters/clust/instances/inst"},
+		{desc: "project starting with a digit", in: "projects/1proj/locations/reg/clusters/clust/instances/inst"},
+		{desc: "project ending in a hyphen", in: "projects/proj-/locations/reg/clusters/clust/instances/inst"},
+		{desc: "project with an underscore", in: "projects/my_proj/locations/reg/clusters/clust/instances/inst"},
+		{desc: "project with a slash", in: "projects/a/b/locations/reg/clusters/clust/instances/inst"},
+		{desc: "missing instance", in: "projects/proj/locations/reg/clusters/clust/instances/"},
+		{desc: "extra path segment", in: "projects/proj/locations/reg/clusters/clust/instances/inst/extra"},
+		{desc: "leading path segment", in: "v1/projects/proj/locations/reg/clusters/clust/instances/inst"},
+		{desc: "unknown resource", in: "projects/proj/locations/reg/clusters/clust/nodes/node"},
+	}
+	for _, tc := range invalid {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, _, _, _, err := ParseInstanceURI(tc.in); err == nil {
+				t.Fatalf("want error for %v, got nil", tc.in)
+			}
+		})
+	}
+}
+
+func TestUnixSocketNamePreservesCase(t *testing.T) {
+	in := "projects/PROJ/locations/REG/clusters/CLUST/instances/INST"
+
+	got, err := unixSocketName("", in, true)
+	if err != nil {
+		t.Fatalf("unixSocketName error: %v", err)
+	}
+	if want := "PROJ.REG.CLUST.INST"; got != want {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+
+	got, err = unixSocketName("", in, false)
+	if err != nil {
+		t.Fatalf("unixSocketName error: %v", err)
+	}
+	if want := "proj.reg.clust.inst"; got != want {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+}
+
 func TestToFullURI(t *testing.T) {
 	tcs := []struct {
 		desc    string
@@ -0,0 +1,165 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/alloydb"
+	"google.golang.org/api/option"
+	"google.golang.org/api/transport"
+)
+
+// googleTokenInfoURL is the endpoint that reports the principal of an OAuth2
+// access token.
+const googleTokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// cloudPlatformScope is the OAuth2 scope the Proxy requests for its
+// credentials.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// principalTimeout bounds how long the Proxy spends determining the IAM
+// principal on startup.
+const principalTimeout = 5 * time.Second
+
+// logPrincipal logs the email of the IAM principal the Proxy authorizes as.
+// Failing to determine the principal does not prevent the Proxy from
+// starting, so any error is logged and otherwise ignored. Determining the
+// principal may require network requests, so callers run logPrincipal in its
+// own goroutine. Config.LookupPrincipal, when set, replaces the lookup.
+func logPrincipal(ctx context.Context, l alloydb.Logger, c Config) {
+	ctx, cancel := context.WithTimeout(ctx, principalTimeout)
+	defer cancel()
+	lookup := c.LookupPrincipal
+	if lookup == nil {
+		lookup = func(ctx context.Context, c Config) (string, error) {
+			return principal(ctx, c, googleTokenInfoURL)
+		}
+	}
+	email, err := lookup(ctx, c)
+	if err != nil {
+		l.Infof("Unable to determine the IAM principal: %v", err)
+		return
+	}
+	l.Infof("Authorizing as %v", email)
+}
+
+// discardLogger drops all messages. It keeps the lookup of the principal
+// from repeating the messages logged when the dialer is created.
+type discardLogger struct{}
+
+func (discardLogger) Debugf(string, ...interface{}) {}
+func (discardLogger) Infof(string, ...interface{})  {}
+func (discardLogger) Errorf(string, ...interface{}) {}
+
+// principal returns the email of the IAM principal described by the
+// credentials in c. Service account keys and impersonation name the principal
+// directly. Otherwise, principal looks up the principal of an access token
+// from the same credentials at tokenInfoURL, through the same HTTP client as
+// the dialer.
+func principal(ctx context.Context, c Config, tokenInfoURL string) (string, error) {
+	switch {
+	case c.ImpersonationChain != "":
+		target, _ := parseImpersonationChain(c.ImpersonationChain)
+		return target, nil
+	case c.CredentialsFile != "":
+		b, err := os.ReadFile(c.CredentialsFile)
+		if err != nil {
+			return "", err
+		}
+		if email := clientEmail(b); email != "" {
+			return email, nil
+		}
+	case c.CredentialsJSON != "":
+		if email := clientEmail([]byte(c.CredentialsJSON)); email != "" {
+			return email, nil
+		}
+	}
+	_, copts, err := credentialsOpt(c, discardLogger{})
+	if err != nil {
+		return "", err
+	}
+	creds, err := transport.Creds(ctx, append(copts, option.WithScopes(cloudPlatformScope))...)
+	if err != nil {
+		return "", err
+	}
+	if email := clientEmail(creds.JSON); email != "" {
+		return email, nil
+	}
+	hc := http.DefaultClient
+	if c.AdminAPIProxyURL != "" {
+		if hc, err = adminAPIClient(c.AdminAPIProxyURL, c.UserAgent, copts); err != nil {
+			return "", err
+		}
+	}
+	tok, err := creds.TokenSource.Token()
+	if err != nil {
+		return "", err
+	}
+	return tokenInfoEmail(ctx, hc, tokenInfoURL, tok.AccessToken)
+}
+
+// clientEmail returns the client_email of service account key JSON, or the
+// empty string if b holds another kind of credentials.
+func clientEmail(b []byte) string {
+	var key struct {
+		ClientEmail string `json:"client_email"`
+	}
+	if err := json.Unmarshal(b, &key); err != nil {
+		return ""
+	}
+	return key.ClientEmail
+}
+
+// tokenInfoEmail returns the email of the principal that owns the access
+// token, sending the request to tokenInfoURL with hc.
+func tokenInfoEmail(ctx context.Context, hc *http.Client, tokenInfoURL, token string) (string, error) {
+	form := url.Values{"access_token": {token}}
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, tokenInfoURL, strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := hc.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var info struct {
+		Email            string `json:"email"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("failed to decode token info: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token info request failed (status %d): %v",
+			resp.StatusCode, info.ErrorDescription)
+	}
+	if info.Email == "" {
+		return "", errors.New("token info does not include an email")
+	}
+	return info.Email, nil
+}
@@ -15,26 +15,44 @@
 package proxy
 
 import (
-	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"cloud.google.com/go/alloydbconn"
+	"cloud.google.com/go/alloydbconn/errtype"
 	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/alloydb"
 	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/internal/gcloud"
+	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/internal/log"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"go.opencensus.io/trace"
+	netproxy "golang.org/x/net/proxy"
 	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/impersonate"
 	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 )
 
 // InstanceConnConfig holds the configuration for an individual instance
@@ -68,6 +86,42 @@ type InstanceConnConfig struct {
 
 	// PSC enables the Proxy to connect to the instance's PSC endpoint.
 	PSC *bool
+
+	// PrivateIP tells the proxy to connect to the instance's private IP
+	// address, even when public IP or PSC is enabled for all instances.
+	PrivateIP *bool
+
+	// ConnectTimeout overrides Config.DialTimeout for the instance.
+	ConnectTimeout time.Duration
+
+	// TraceSampleRate overrides the global trace sample rate for the spans of
+	// the instance's connections. A higher value means fewer traces. When
+	// zero, the global rate applies.
+	TraceSampleRate int
+}
+
+// Validate reports an error if the instance configuration contains options
+// that cannot be used together.
+func (i InstanceConnConfig) Validate() error {
+	switch {
+	case i.Addr != "" && i.UnixSocket != "":
+		return errors.New("cannot specify both address and unix-socket")
+	case i.Port != 0 && i.UnixSocket != "":
+		return errors.New("cannot specify both port and unix-socket")
+	case i.Addr != "" && i.UnixSocketPath != "":
+		return errors.New("cannot specify both address and unix-socket-path")
+	case i.Port != 0 && i.UnixSocketPath != "":
+		return errors.New("cannot specify both port and unix-socket-path")
+	case i.UnixSocket != "" && i.UnixSocketPath != "":
+		return errors.New("cannot specify both unix-socket-path and unix-socket")
+	case i.PublicIP != nil && *i.PublicIP && i.PSC != nil && *i.PSC:
+		return errors.New("cannot specify both public-ip and psc")
+	case i.PrivateIP != nil && *i.PrivateIP && i.PublicIP != nil && *i.PublicIP:
+		return errors.New("cannot specify both private-ip and public-ip")
+	case i.PrivateIP != nil && *i.PrivateIP && i.PSC != nil && *i.PSC:
+		return errors.New("cannot specify both private-ip and psc")
+	}
+	return nil
 }
 
 // Config contains all the configuration provided by the caller.
@@ -91,6 +145,11 @@ type Config struct {
 	// PSC enables connections via the PSC endpoint for all instances.
 	PSC bool
 
+	// PrivateIP enables connections via the database server's private IP
+	// address for all instances. This is the default, but unlike the default
+	// it cannot be combined with PublicIP or PSC.
+	PrivateIP bool
+
 	// LazyRefresh configures the Go Connector to retrieve connection info
 	// lazily and as-needed. Otherwise, no background refresh cycle runs. This
 	// setting is useful in environments where the CPU may be throttled outside
@@ -106,6 +165,11 @@ type Config struct {
 	// CredentialsJSON is a JSON representation of the service account key.
 	CredentialsJSON string
 
+	// ReloadCredentials configures the Client to support ReloadCredentials,
+	// which re-reads CredentialsFile, e.g., after a service account key is
+	// rotated.
+	ReloadCredentials bool
+
 	// GcloudAuth set whether to use Gcloud's config helper to retrieve a
 	// token for authentication.
 	GcloudAuth bool
@@ -114,13 +178,34 @@ type Config struct {
 	Addr string
 
 	// Port is the initial port to bind to. Subsequent instances bind to
-	// increments from this value.
+	// increments from this value. A value of 0 binds each instance to a port
+	// chosen by the operating system.
 	Port int
 
+	// DisableIPv6 restricts TCP listeners and connections to instances to
+	// IPv4, for environments that advertise IPv6 but cannot route it.
+	DisableIPv6 bool
+
+	// ListenNetwork is the network TCP listeners bind with: "tcp", "tcp4",
+	// or "tcp6". The default of "tcp" binds an unspecified address on both
+	// IPv4 and IPv6 where available. DisableIPv6 takes precedence.
+	ListenNetwork string
+
+	// BindRetries is the number of times to retry binding a listener that
+	// fails to bind, e.g., while a previous process releases the port.
+	// Retries back off from 100ms to 2s. A zero-value does not retry.
+	BindRetries int
+
 	// UnixSocket is the directory where Unix sockets will be created,
 	// connected to any Instances. If set, takes precedence over Addr and Port.
 	UnixSocket string
 
+	// DisableInstanceURILowercasing preserves the case of instance URIs when
+	// naming Unix socket directories. By default, names are lowercased so
+	// that a socket path is the same on case-sensitive and case-insensitive
+	// file systems.
+	DisableInstanceURILowercasing bool
+
 	// FUSEDir enables a file system in user space at the provided path that
 	// connects to the requested instance only when a client requests it.
 	FUSEDir string
@@ -130,9 +215,44 @@ type Config struct {
 	// is not accessed directly.
 	FUSETempDir string
 
+	// FUSESocketSuffix is the port number used in the name of the Postgres
+	// socket that the FUSE mount creates for each instance, e.g., 6432 for
+	// .s.PGSQL.6432. Defaults to 5432.
+	FUSESocketSuffix string
+
+	// FUSENoAutoconnect excludes FUSE instances from connection checks until
+	// a client connects to the instance's socket. Otherwise, looking up a
+	// socket path (e.g., with stat) is enough for health checks to dial the
+	// instance.
+	FUSENoAutoconnect bool
+
+	// FUSEMaxInstances is the maximum number of instances the FUSE mount
+	// creates sockets for. Lookups of further instances fail. Zero means no
+	// limit.
+	FUSEMaxInstances uint64
+
+	// FUSEFallback configures the Proxy to start listeners for the
+	// configured Instances instead of failing when FUSEDir is set but FUSE
+	// is not supported on the host.
+	FUSEFallback bool
+
+	// DualListener configures the Proxy to also start a TCP listener for each
+	// instance that uses a Unix socket. Both listeners connect to the same
+	// instance.
+	DualListener bool
+
+	// NamedPipe configures the Proxy to listen on a Windows named pipe for
+	// each instance, e.g., \\.\pipe\alloydb-project.region.cluster.instance,
+	// instead of a TCP port. Named pipes are only supported on Windows.
+	NamedPipe bool
+
 	// APIEndpointURL is the URL of the AlloyDB Admin API.
 	APIEndpointURL string
 
+	// AdminAPIProxyURL is the URL of an HTTP or SOCKS5 proxy for requests to
+	// the AlloyDB Admin API. Other traffic does not use the proxy.
+	AdminAPIProxyURL string
+
 	// Instances are configuration for individual instances. Instance
 	// configuration takes precedence over global configuration.
 	Instances []InstanceConnConfig
@@ -142,11 +262,67 @@ type Config struct {
 	// connections. A zero-value indicates no limit.
 	MaxConnections uint64
 
-	// WaitBeforeClose sets the duration to wait after receiving a shutdown signal
+	// MaxConnectionsQueue is the longest a new connection waits for an open
+	// connection to close once MaxConnections is reached, before it is
+	// refused. A zero-value refuses such connections immediately.
+	MaxConnectionsQueue time.Duration
+
+	// AppendApplicationName, when set, is appended to the application_name
+	// parameter of each client's startup message before it is forwarded to
+	// the instance, so that queries can be attributed to the Proxy on the
+	// server. Postgres truncates application names to 63 bytes.
+	AppendApplicationName string
+
+	// FriendlyMaxConnError configures the Proxy to send a Postgres error to
+	// clients refused because of MaxConnections, instead of only closing the
+	// connection. The Proxy reads the client's startup message to do so.
+	FriendlyMaxConnError bool
+
+	// NewConnectionRate limits the rate of new connections per second. When
+	// a new connection would wait longer than a second for the limiter, the
+	// connection is refused. A zero-value indicates no limit.
+	NewConnectionRate float64
+
+	// NewConnectionBurst is the number of new connections allowed at once
+	// before NewConnectionRate applies. Values less than 1 are treated as 1.
+	NewConnectionBurst int
+
+	// NewConnectionRatePerInstance applies NewConnectionRate to each instance
+	// separately rather than to all instances together.
+	NewConnectionRatePerInstance bool
+
+	// StartupDelay is the time to wait between starting the listeners of
+	// each instance, to spread out the load of starting many instances.
+	StartupDelay time.Duration
+
+	// MaxStartupTime is the maximum time the Proxy may take to start, after
+	// which it exits with an error. When zero, there is no limit.
+	MaxStartupTime time.Duration
+
+	// DialTimeout is the maximum time to wait when connecting to an instance
+	// for a new client connection. Defaults to 30 seconds.
+	DialTimeout time.Duration
+
+	// MaxConnectionLifetime is the longest a client connection may stay open.
+	// Once exceeded, the Proxy closes the connection after forwarding any
+	// data in flight. Zero means no limit.
+	MaxConnectionLifetime time.Duration
+
+	// WaitBeforeClose sets the duration to wait after receiving a TERM signal
 	// but before closing the process. Not setting this field means to initiate
 	// the shutdown process immediately.
 	WaitBeforeClose time.Duration
 
+	// RefuseNewOnSigterm configures the Proxy to close its listeners as soon
+	// as it receives a TERM signal, instead of after WaitBeforeClose, while
+	// open connections continue.
+	RefuseNewOnSigterm bool
+
+	// WaitBeforeCloseOnSigint sets the duration to wait after receiving an INT
+	// signal but before closing the process. Not setting this field means to
+	// initiate the shutdown process immediately.
+	WaitBeforeCloseOnSigint time.Duration
+
 	// WaitOnClose sets the duration to wait for connections to close before
 	// shutting down. Not setting this field means to close immediately
 	// regardless of any open connections.
@@ -163,6 +339,25 @@ type Config struct {
 	// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
 	StructuredLogs bool
 
+	// LogFormat sets the format of log messages. It is one of text, json
+	// (the same as StructuredLogs), or logfmt. When empty, messages are
+	// written as text, unless StructuredLogs is set.
+	LogFormat string
+
+	// LogTimestampFormat sets the format of the time written with each log
+	// message. It is either a named format (e.g., rfc3339nano or unixmilli)
+	// or a Go time layout. When empty, each logger uses its default format.
+	LogTimestampFormat string
+
+	// LogSource adds the source file and line that logged each message.
+	LogSource bool
+
+	// LogInstances limits connection log messages to the listed instances,
+	// given as either instance URIs or short names (project.region.cluster.
+	// instance). Errors are logged for all instances. When empty, messages
+	// are logged for all instances.
+	LogInstances []string
+
 	// DebugLogs enables debug logging and is useful when diagnosing surprising
 	// Proxy behavior.
 	DebugLogs bool
@@ -177,25 +372,66 @@ type Config struct {
 	// TelemetryTracingSampleRate sets the rate at which traces are
 	// samples. A higher value means fewer traces.
 	TelemetryTracingSampleRate int
-	// DisableTraces disables tracing when TelemetryProject is set.
+	// DisableTraces disables tracing when TelemetryProject or OTLPEndpoint
+	// is set.
 	DisableTraces bool
-	// DisableMetrics disables metrics when TelemetryProject is set.
+	// DisableMetrics disables metrics when TelemetryProject or OTLPEndpoint
+	// is set.
 	DisableMetrics bool
 
+	// OTLPEndpoint enables sending metrics and traces to the OpenTelemetry
+	// collector at the provided host:port using OTLP over gRPC. It may not
+	// be used with TelemetryProject.
+	OTLPEndpoint string
+	// OTLPInsecure disables TLS when connecting to OTLPEndpoint.
+	OTLPInsecure bool
+
 	// Prometheus enables a Prometheus endpoint served at the address and
 	// port specified by HTTPAddress and HTTPPort.
 	Prometheus bool
 	// PrometheusNamespace configures the namespace underwhich metrics are written.
 	PrometheusNamespace string
+	// MetricsPath is the path at which the Prometheus endpoint is served.
+	MetricsPath string
+
+	// DeploymentLabel identifies the environment of the Proxy (e.g., prod or
+	// staging). When set, it is added to all structured logs and exported
+	// metrics.
+	DeploymentLabel string
+
+	// TelemetryLabels are added to all exported metrics.
+	TelemetryLabels map[string]string
 
 	// HealthCheck enables a health check server. It's address and port are
 	// specified by HTTPAddress and HTTPPort.
 	HealthCheck bool
 
+	// QuietHealthChecks suppresses the info messages about starting the
+	// health check server and enabling admin server endpoints.
+	QuietHealthChecks bool
+
+	// ReadinessMaxConnectionsPct is the percentage of MaxConnections that
+	// open connections may reach before the readiness check fails. A
+	// zero-value means the readiness check fails only when MaxConnections
+	// is reached.
+	ReadinessMaxConnectionsPct uint64
+
+	// ReadinessTCPProbe configures the readiness check to open a TCP
+	// connection to each instance's server-side proxy, without a TLS
+	// handshake or authentication, to verify the network path.
+	ReadinessTCPProbe bool
+
 	// HTTPAddress sets the address for the health check and prometheus server.
 	HTTPAddress string
 	// HTTPPort sets the port for the health check and prometheus server.
 	HTTPPort string
+
+	// HTTPReadHeaderTimeout is the maximum duration for reading request
+	// headers on the health check, prometheus, and admin servers.
+	HTTPReadHeaderTimeout time.Duration
+	// HTTPShutdownTimeout is the maximum duration the health check,
+	// prometheus, and admin servers wait for in-flight requests on shutdown.
+	HTTPShutdownTimeout time.Duration
 	// AdminPort configures the port for the localhost-only admin server.
 	AdminPort string
 
@@ -209,16 +445,203 @@ type Config struct {
 	// appended to the default user agent.
 	OtherUserAgents string
 
+	// UserAgentOverride replaces the default user agent. OtherUserAgents are
+	// appended to it.
+	UserAgentOverride string
+
+	// UserAgentSuffix is an identifier, e.g., of a deployment, appended to
+	// the user agent after any OtherUserAgents.
+	UserAgentSuffix string
+
+	// HealthCheckInterval is how often the health check server dials each
+	// instance in the background to determine readiness. When zero, readiness
+	// does not dial instances in the background.
+	HealthCheckInterval time.Duration
+
+	// HealthCheckOnStartupOnly stops the health check server once /startup
+	// has reported success HealthCheckStartupSuccesses times, or once
+	// HealthCheckStartupTimeout has elapsed, to free its port after startup.
+	HealthCheckOnStartupOnly bool
+
+	// HealthCheckStartupSuccesses is the number of successful responses from
+	// /startup after which HealthCheckOnStartupOnly stops the health check
+	// server.
+	HealthCheckStartupSuccesses int
+
+	// HealthCheckStartupTimeout is how long after starting the health check
+	// server HealthCheckOnStartupOnly stops it, whether or not /startup has
+	// succeeded. A zero-value indicates no timeout.
+	HealthCheckStartupTimeout time.Duration
+
+	// SkipURIValidation accepts instance URIs that do not match the expected
+	// format and passes them to the dialer as is. Listeners for such
+	// instances are named after the URI with path separators replaced.
+	SkipURIValidation bool
+
+	// RegionAliases maps short names used in place of a region in instance
+	// URIs to the region they stand for, e.g., us to us-central1.
+	RegionAliases map[string]string
+
 	// RunConnectionTest determines whether the Proxy should attempt a connection
 	// to all specified instances to verify the network path is valid.
 	RunConnectionTest bool
 
-	// StaticConnectionInfo is the file path for a static connection info JSON
-	// file. See the proxy help message for details on its format.
+	// StaticConnectionInfo is a comma-separated list of file paths for static
+	// connection info JSON files. The files are merged and must share the same
+	// key pair. See the proxy help message for details on its format.
 	StaticConnectionInfo string
 
+	// LookupPrincipal, when set, replaces the lookup of the IAM principal
+	// that the Proxy logs on startup and after reloading credentials.
+	LookupPrincipal func(context.Context, Config) (string, error) `json:"-"`
+
 	// ExitZeroOnSigterm exits with 0 exit code when Sigterm received
 	ExitZeroOnSigterm bool
+
+	// PIDFile is the path of a file the Proxy writes its process ID to once
+	// it has started. The file is removed on shutdown.
+	PIDFile string
+
+	// ConnectionAuditFile is the path of a file the Proxy appends a JSON line
+	// to whenever a connection to an instance is established or closed. Each
+	// line includes the time, instance, connection ID, and client address,
+	// and close events include the bytes copied in each direction.
+	ConnectionAuditFile string
+
+	// ManualStart configures the Client to bind all listeners, but to accept
+	// connections only after Start has been called.
+	ManualStart bool
+
+	// ClientTLSCert is the path to a PEM encoded certificate the Proxy presents
+	// to local clients. When set with ClientTLSKey, all local listeners
+	// require TLS and refuse unencrypted connections.
+	ClientTLSCert string
+	// ClientTLSKey is the path to the PEM encoded private key for
+	// ClientTLSCert.
+	ClientTLSKey string
+	// ClientTLSClientCA is the path to a PEM encoded CA bundle. When set, local
+	// clients must present a certificate signed by one of the CAs.
+	ClientTLSClientCA string
+}
+
+// redacted replaces the value of secret configuration fields.
+const redacted = "<redacted>"
+
+// Redacted returns a copy of the Config with secrets, i.e., the OAuth2 token
+// and the credentials JSON, replaced by a placeholder, and with any password
+// in the Admin API proxy URL masked.
+func (c Config) Redacted() Config {
+	if c.Token != "" {
+		c.Token = redacted
+	}
+	if c.CredentialsJSON != "" {
+		c.CredentialsJSON = redacted
+	}
+	if c.AdminAPIProxyURL != "" {
+		c.AdminAPIProxyURL = redactURL(c.AdminAPIProxyURL)
+	}
+	if c.Instances != nil {
+		c.Instances = append([]InstanceConnConfig(nil), c.Instances...)
+	}
+	if c.LogInstances != nil {
+		c.LogInstances = append([]string(nil), c.LogInstances...)
+	}
+	return c
+}
+
+// String formats the Config as JSON with secrets redacted.
+func (c Config) String() string {
+	b, err := json.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("invalid config: %v", err)
+	}
+	return string(b)
+}
+
+// clientTLSConfig returns the TLS configuration used for local client
+// connections, or nil if client TLS is not configured.
+func clientTLSConfig(c *Config) (*tls.Config, error) {
+	if c.ClientTLSCert == "" && c.ClientTLSKey == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.ClientTLSCert, c.ClientTLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client TLS certificate: %v", err)
+	}
+	tc := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS13,
+	}
+	if c.ClientTLSClientCA != "" {
+		ca, err := os.ReadFile(c.ClientTLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in client CA %q", c.ClientTLSClientCA)
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tc, nil
+}
+
+const (
+	privateIP = "private IP"
+	publicIP  = "public IP"
+	pscIP     = "PSC"
+)
+
+// ipType reports which of the instance's endpoints the Proxy connects to,
+// based on the instance and global configuration.
+func ipType(c Config, i InstanceConnConfig) string {
+	switch {
+	// If private IP is enabled at the instance level, ignore any global
+	// setting.
+	case i.PrivateIP != nil && *i.PrivateIP:
+		return privateIP
+	// If PSC is enabled at the instance level, or PSC is enabled globally,
+	// connect to the PSC endpoint.
+	case i.PSC != nil && *i.PSC || i.PSC == nil && c.PSC:
+		return pscIP
+	// If public IP is enabled at the instance level, or public IP is enabled
+	// globally, connect to the public IP.
+	case i.PublicIP != nil && *i.PublicIP || i.PublicIP == nil && c.PublicIP:
+		return publicIP
+	default:
+		return privateIP
+	}
+}
+
+// dialSpanName is the name of the span that covers connecting to an instance
+// with a TraceSampleRate.
+const dialSpanName = "alloydb-auth-proxy/Dial"
+
+// traceSampler returns the sampler for the spans of the instance's
+// connections, or nil if the global sampler applies.
+func traceSampler(i InstanceConnConfig) trace.Sampler {
+	if i.TraceSampleRate <= 0 {
+		return nil
+	}
+	return trace.ProbabilitySampler(1 / float64(i.TraceSampleRate))
+}
+
+// defaultDialTimeout is the maximum time to wait when connecting to an
+// instance when neither the instance nor the global configuration set one.
+const defaultDialTimeout = 30 * time.Second
+
+// dialTimeout returns the maximum time to wait when connecting to the
+// instance, preferring the instance configuration over the global one.
+func dialTimeout(c Config, i InstanceConnConfig) time.Duration {
+	switch {
+	case i.ConnectTimeout > 0:
+		return i.ConnectTimeout
+	case c.DialTimeout > 0:
+		return c.DialTimeout
+	default:
+		return defaultDialTimeout
+	}
 }
 
 // dialOptions interprets appropriate dial options for a particular instance
@@ -226,12 +649,10 @@ type Config struct {
 func dialOptions(c Config, i InstanceConnConfig) []alloydbconn.DialOption {
 	var opts []alloydbconn.DialOption
 
-	// If public IP is enabled at the instance level, or public IP is enabled
-	// globally, add the option.
-	if i.PublicIP != nil && *i.PublicIP || i.PublicIP == nil && c.PublicIP {
+	switch ipType(c, i) {
+	case publicIP:
 		opts = append(opts, alloydbconn.WithPublicIP())
-	}
-	if i.PSC != nil && *i.PSC || i.PSC == nil && c.PSC {
+	case pscIP:
 		opts = append(opts, alloydbconn.WithPSC())
 	}
 	return opts
@@ -252,7 +673,9 @@ func parseImpersonationChain(chain string) (string, []string) {
 	return target, delegates
 }
 
-func credentialsOpt(c Config, l alloydb.Logger) (alloydbconn.Option, error) {
+// credentialsOpt returns the dialer option for the configured credentials,
+// along with the equivalent options for other Google API clients.
+func credentialsOpt(c Config, l alloydb.Logger) (alloydbconn.Option, []option.ClientOption, error) {
 	// If service account impersonation is configured, set up an impersonated
 	// credentials token source.
 	if c.ImpersonationChain != "" {
@@ -273,7 +696,7 @@ func credentialsOpt(c Config, l alloydb.Logger) (alloydbconn.Option, error) {
 			l.Infof("Impersonating service account with gcloud user credentials")
 			ts, err := gcloud.TokenSource()
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			iopts = append(iopts, option.WithTokenSource(ts))
 		default:
@@ -290,44 +713,87 @@ func credentialsOpt(c Config, l alloydb.Logger) (alloydbconn.Option, error) {
 			iopts...,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		return alloydbconn.WithTokenSource(ts), nil
+		return alloydbconn.WithTokenSource(ts), []option.ClientOption{option.WithTokenSource(ts)}, nil
 	}
 	// Otherwise, configure credentials as usual.
 	switch {
 	case c.Token != "":
 		l.Infof("Authorizing with OAuth2 token")
-		return alloydbconn.WithTokenSource(
-			oauth2.StaticTokenSource(&oauth2.Token{AccessToken: c.Token}),
-		), nil
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: c.Token})
+		return alloydbconn.WithTokenSource(ts), []option.ClientOption{option.WithTokenSource(ts)}, nil
 	case c.CredentialsFile != "":
 		l.Infof("Authorizing with the credentials file at %q", c.CredentialsFile)
-		return alloydbconn.WithCredentialsFile(c.CredentialsFile), nil
+		return alloydbconn.WithCredentialsFile(c.CredentialsFile),
+			[]option.ClientOption{option.WithCredentialsFile(c.CredentialsFile)}, nil
 	case c.CredentialsJSON != "":
 		l.Infof("Authorizing with JSON credentials environment variable")
-		return alloydbconn.WithCredentialsJSON([]byte(c.CredentialsJSON)), nil
+		return alloydbconn.WithCredentialsJSON([]byte(c.CredentialsJSON)),
+			[]option.ClientOption{option.WithCredentialsJSON([]byte(c.CredentialsJSON))}, nil
 	case c.GcloudAuth:
 		l.Infof("Authorizing with gcloud user credentials")
 		ts, err := gcloud.TokenSource()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		return alloydbconn.WithTokenSource(ts), nil
+		return alloydbconn.WithTokenSource(ts), []option.ClientOption{option.WithTokenSource(ts)}, nil
 	default:
 		l.Infof("Authorizing with Application Default Credentials")
 		// Return no-op options to avoid having to handle nil in caller code
-		return alloydbconn.WithOptions(), nil
+		return alloydbconn.WithOptions(), nil, nil
+	}
+}
+
+// adminAPIClient returns an HTTP client that sends authorized AlloyDB Admin
+// API requests through the HTTP or SOCKS5 proxy at proxyURL.
+func adminAPIClient(proxyURL, userAgent string, opts []option.ClientOption) (*http.Client, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		// Report only the underlying parse error: a *url.Error repeats the
+		// URL, which may include a password.
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return nil, fmt.Errorf("invalid admin API proxy URL: %v", err)
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = http.ProxyURL(u)
+	opts = append(opts,
+		option.WithScopes("https://www.googleapis.com/auth/cloud-platform"),
+		option.WithUserAgent(userAgent),
+	)
+	t, err := htransport.NewTransport(context.Background(), base, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: t}, nil
+}
+
+// redactURL returns raw with any password in its userinfo masked, so the URL
+// is safe to log. A value that does not parse is masked entirely.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
 	}
+	return u.Redacted()
 }
 
 // DialerOptions builds appropriate list of options from the Config
 // values for use by alloydbconn.NewClient()
 func (c *Config) DialerOptions(l alloydb.Logger) ([]alloydbconn.Option, error) {
+	return c.dialerOptions(l, nil)
+}
+
+// dialerOptions builds the options for alloydbconn.NewDialer, recording the
+// outcome of certificate refreshes in rs when it is not nil.
+func (c *Config) dialerOptions(l alloydb.Logger, rs *refreshStatus) ([]alloydbconn.Option, error) {
 	opts := []alloydbconn.Option{
 		alloydbconn.WithUserAgent(c.UserAgent),
 	}
-	co, err := credentialsOpt(*c, l)
+	co, copts, err := credentialsOpt(*c, l)
 	if err != nil {
 		return nil, err
 	}
@@ -337,29 +803,33 @@ func (c *Config) DialerOptions(l alloydb.Logger) ([]alloydbconn.Option, error) {
 		opts = append(opts, alloydbconn.WithAdminAPIEndpoint(c.APIEndpointURL))
 	}
 
+	if c.AdminAPIProxyURL != "" {
+		l.Infof("Sending AlloyDB Admin API requests through proxy %v", redactURL(c.AdminAPIProxyURL))
+		hc, err := adminAPIClient(c.AdminAPIProxyURL, c.UserAgent, copts)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, alloydbconn.WithHTTPClient(hc))
+	}
+
 	if c.AutoIAMAuthN {
 		opts = append(opts, alloydbconn.WithIAMAuthN())
 	}
 
-	if c.DebugLogs {
-		opts = append(opts, alloydbconn.WithDebugLogger(l))
-	}
+	opts = append(opts, alloydbconn.WithDebugLogger(
+		&refreshLogger{l: l, debug: c.DebugLogs, status: rs},
+	))
 
 	if c.LazyRefresh {
 		opts = append(opts, alloydbconn.WithLazyRefresh())
 	}
 	if c.StaticConnectionInfo != "" {
-		f, err := os.Open(c.StaticConnectionInfo)
-		if err != nil {
-			return nil, err
-		}
-		defer f.Close()
-		data, err := io.ReadAll(f)
+		data, err := loadStaticConnectionInfo(c.StaticConnectionInfo, time.Now())
 		if err != nil {
 			return nil, err
 		}
 		opts = append(opts, alloydbconn.WithStaticConnectionInfo(
-			bytes.NewReader(data),
+			&rewindReader{data: data},
 		))
 	}
 
@@ -376,18 +846,38 @@ func newPortConfig(global int) *portConfig {
 	}
 }
 
-// nextPort returns the next port based on the initial global value.
+// nextPort returns the next port based on the initial global value. When the
+// global value is 0, every call returns 0 so that each listener receives its
+// own port assigned by the operating system.
 func (c *portConfig) nextPort() int {
 	p := c.global
+	if p == 0 {
+		return 0
+	}
 	c.global++
 	return p
 }
 
+const (
+	// domainPattern matches a DNS domain of two or more labels, e.g.,
+	// google.com or example.co.uk.
+	domainPattern = `(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z](?:[a-z0-9-]*[a-z0-9])?`
+	// projectIDPattern matches a project ID, e.g., my-project-123.
+	projectIDPattern = `[a-z](?:[a-z0-9-]*[a-z0-9])?`
+)
+
 var (
 	// Instance URI is in the format:
 	// 'projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>'
-	// Additionally, we have to support legacy "domain-scoped" projects (e.g. "google.com:PROJECT")
-	instURIRegex = regexp.MustCompile("projects/([^:]+(?::[^:]+)?)/locations/(.+)/clusters/(.+)/instances/(.+)")
+	// Additionally, we have to support legacy "domain-scoped" projects (e.g.
+	// "google.com:PROJECT"), where a DNS domain and a colon prefix the project
+	// ID. Project IDs start with a letter, contain only letters, digits, and
+	// hyphens, and do not end with a hyphen. Matching is case-insensitive
+	// because instance URIs are lowercased only when naming Unix sockets.
+	instURIRegex = regexp.MustCompile(
+		`(?i)^projects/((?:` + domainPattern + `:)?` + projectIDPattern + `)` +
+			`/locations/([^/:]+)/clusters/([^/:]+)/instances/([^/:]+)$`,
+	)
 	// unixRegex is the expected format for a Unix socket
 	// e.g. project.region.cluster.instance
 	unixRegex = regexp.MustCompile(`([^:]+(?:-[^:]+)?)\.(.+)\.(.+)\.(.+)`)
@@ -412,10 +902,25 @@ func ShortInstURI(inst string) (string, error) {
 	return strings.Join([]string{p, r, c, i}, "."), nil
 }
 
+// fallbackShortName derives a name for an instance URI that does not match
+// the expected format, for use in logs and as a Unix socket directory. The
+// result is a single path element, e.g., projects.p.locations.r.x.y.
+func fallbackShortName(inst string) string {
+	return strings.NewReplacer("/", ".", ":", "_", `\`, ".").Replace(strings.Trim(inst, "/"))
+}
+
 // UnixSocketDir returns a shorted instance connection name to prevent
 // exceeding the Unix socket length, e.g., project.region.cluster.instance
 func UnixSocketDir(dir, inst string) (string, error) {
-	project, region, cluster, name, err := ParseInstanceURI(strings.ToLower(inst))
+	return unixSocketName(dir, inst, false)
+}
+
+// unixSocketName is UnixSocketDir, optionally preserving the case of inst.
+func unixSocketName(dir, inst string, preserveCase bool) (string, error) {
+	if !preserveCase {
+		inst = strings.ToLower(inst)
+	}
+	project, region, cluster, name, err := ParseInstanceURI(inst)
 	if err != nil {
 		return "", err
 	}
@@ -461,33 +966,154 @@ type Client struct {
 
 	dialer alloydb.Dialer
 
+	// reloader recreates the dialer to reload credentials. When nil,
+	// ReloadCredentials reports an error.
+	reloader *reloadableDialer
+
+	// refresh records the outcome of the connector's certificate
+	// refreshes. It remains empty when the caller supplies the dialer.
+	refresh *refreshStatus
+
+	// audit records connection events when ConnectionAuditFile is set.
+	audit *auditLog
+
 	// mnts is a list of all mounted sockets for this client
 	mnts []*socketMount
 
 	logger alloydb.Logger
 
+	// clientTLS is the TLS configuration for local client connections. When
+	// nil, local connections are not encrypted.
+	clientTLS *tls.Config
+
+	// logInstances is the set of short instance names whose connection
+	// messages are logged. When nil, all instances are logged.
+	logInstances map[string]bool
+
+	// limiter limits the rate of new connections to all instances. When nil,
+	// there is no global limit.
+	limiter *rate.Limiter
+
+	// connSlots holds a value for each open connection when new connections
+	// queue for MaxConnectionsQueue. When nil, new connections over
+	// MaxConnections are refused immediately.
+	connSlots chan struct{}
+
+	// acceptMu protects acceptCh.
+	acceptMu sync.Mutex
+	// acceptCh is closed when the Client is accepting new connections.
+	acceptCh chan struct{}
+
+	// closeOnce guards closing closed.
+	closeOnce sync.Once
+	// closed is closed when the Client first stops listening, in Close or
+	// CloseListeners, before any listener is closed, so that accept loops can
+	// tell an intentional shutdown apart from a listener failure.
+	closed chan struct{}
+	// listenersClosed reports whether the listeners have been closed, so
+	// they are closed only once.
+	listenersClosed atomic.Bool
+
+	// readyOnce guards closing ready.
+	readyOnce sync.Once
+	// ready is closed once Serve is serving on all listeners.
+	ready chan struct{}
+
+	// principalCtx is the context of lookups of the IAM principal. Close
+	// cancels it with stopPrincipal and waits for lookups in principalWG.
+	principalCtx  context.Context
+	stopPrincipal context.CancelFunc
+	principalWG   sync.WaitGroup
+
 	fuseMount
 }
 
+// newDialer creates a connector dialer from the Config that records the
+// outcome of certificate refreshes in rs.
+func newDialer(ctx context.Context, l alloydb.Logger, conf *Config, rs *refreshStatus) (alloydb.Dialer, error) {
+	dialerOpts, err := conf.dialerOptions(l, rs)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing dialer: %v", err)
+	}
+	d, err := alloydbconn.NewDialer(ctx, dialerOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing dialer: %v", err)
+	}
+	return d, nil
+}
+
 // NewClient completes the initial setup required to get the proxy to a "steady" state.
 func NewClient(ctx context.Context, d alloydb.Dialer, l alloydb.Logger, conf *Config) (*Client, error) {
 	// Check if the caller has configured a dialer.
 	// Otherwise, initialize a new one.
-	if d == nil {
-		dialerOpts, err := conf.DialerOptions(l)
+	var (
+		reloader *reloadableDialer
+		rs       = newRefreshStatus()
+		// ownDialer reports whether the dialer is created here, from the
+		// credentials in conf, rather than supplied by the caller.
+		ownDialer = d == nil
+	)
+	if ownDialer {
+		var err error
+		if conf.ReloadCredentials {
+			reloader, err = newReloadableDialer(func() (alloydb.Dialer, error) {
+				return newDialer(context.Background(), l, conf, rs)
+			})
+			d = reloader
+		} else {
+			d, err = newDialer(ctx, l, conf, rs)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("error initializing dialer: %v", err)
+			return nil, err
 		}
-		d, err = alloydbconn.NewDialer(ctx, dialerOpts...)
+	}
+
+	clientTLS, err := clientTLSConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var audit *auditLog
+	if conf.ConnectionAuditFile != "" {
+		audit, err = newAuditLog(conf.ConnectionAuditFile)
 		if err != nil {
-			return nil, fmt.Errorf("error initializing dialer: %v", err)
+			return nil, fmt.Errorf("failed to open connection audit file: %v", err)
 		}
 	}
 
 	c := &Client{
-		logger: l,
-		dialer: d,
-		conf:   conf,
+		logger:    l,
+		dialer:    d,
+		reloader:  reloader,
+		refresh:   rs,
+		audit:     audit,
+		conf:      conf,
+		clientTLS: clientTLS,
+		acceptCh:  make(chan struct{}),
+		closed:    make(chan struct{}),
+		ready:     make(chan struct{}),
+	}
+	c.principalCtx, c.stopPrincipal = context.WithCancel(ctx)
+	if ownDialer {
+		c.logPrincipal()
+	}
+	if !conf.ManualStart {
+		close(c.acceptCh)
+	}
+	if !conf.NewConnectionRatePerInstance {
+		c.limiter = newConnLimiter(conf)
+	}
+	if conf.MaxConnections > 0 && conf.MaxConnectionsQueue > 0 {
+		c.connSlots = make(chan struct{}, conf.MaxConnections)
+	}
+	if len(conf.LogInstances) > 0 {
+		c.logInstances = make(map[string]bool)
+		for _, inst := range conf.LogInstances {
+			if short, err := ShortInstURI(inst); err == nil {
+				inst = short
+			}
+			c.logInstances[inst] = true
+		}
 	}
 
 	if conf.FUSEDir != "" {
@@ -495,26 +1121,41 @@ func NewClient(ctx context.Context, d alloydb.Dialer, l alloydb.Logger, conf *Co
 	}
 
 	var mnts []*socketMount
-	pc := newPortConfig(conf.Port)
-	for _, inst := range conf.Instances {
-		m, err := newSocketMount(ctx, conf, pc, inst)
-		if err != nil {
-			for _, m := range mnts {
-				mErr := m.Close()
-				if mErr != nil {
-					l.Errorf("failed to close mount: %v", mErr)
-				}
+	closeMounts := func() {
+		for _, m := range mnts {
+			mErr := m.Close()
+			if mErr != nil {
+				l.Errorf("failed to close mount: %v", mErr)
 			}
-			i, instURIErr := ShortInstURI(inst.Name)
-			if instURIErr != nil {
-				// this shouldn't happen because the inst uri is already validated by this point
-				i = inst.Name
+		}
+		_ = c.audit.close()
+	}
+	pc := newPortConfig(conf.Port)
+	for n, inst := range conf.Instances {
+		if n > 0 && conf.StartupDelay > 0 {
+			select {
+			case <-ctx.Done():
+				closeMounts()
+				return nil, ctx.Err()
+			case <-time.After(conf.StartupDelay):
 			}
-			return nil, fmt.Errorf("[%v] Unable to mount socket: %v", i, err)
 		}
+		for _, lc := range listenerConfigs(conf, inst) {
+			m, err := newSocketMount(ctx, lc.conf, pc, lc.inst)
+			if err != nil {
+				closeMounts()
+				i, instURIErr := ShortInstURI(inst.Name)
+				if instURIErr != nil {
+					// The inst uri is already validated by this point, unless
+					// validation was skipped.
+					i = inst.Name
+				}
+				return nil, fmt.Errorf("[%v] Unable to mount socket: %v", i, err)
+			}
 
-		l.Infof("[%s] Listening on %s", m.instShort, m.Addr())
-		mnts = append(mnts, m)
+			l.Infof("[%s] Listening on %s", m.instShort, m.Addr())
+			mnts = append(mnts, m)
+		}
 	}
 
 	c.mnts = mnts
@@ -522,25 +1163,96 @@ func NewClient(ctx context.Context, d alloydb.Dialer, l alloydb.Logger, conf *Co
 	return c, nil
 }
 
+// listenerConfig is the configuration of a single listener for an instance.
+type listenerConfig struct {
+	conf *Config
+	inst InstanceConnConfig
+}
+
+// listenerConfigs returns the configuration of each listener for inst. With
+// DualListener, instances that use a Unix socket also get a TCP listener.
+func listenerConfigs(conf *Config, inst InstanceConnConfig) []listenerConfig {
+	lcs := []listenerConfig{{conf: conf, inst: inst}}
+	usesUnix := (conf.UnixSocket != "" || inst.UnixSocket != "" || inst.UnixSocketPath != "") &&
+		inst.Addr == "" && inst.Port == 0
+	if !conf.DualListener || !usesUnix {
+		return lcs
+	}
+	tcpConf := *conf
+	tcpConf.UnixSocket = ""
+	tcpInst := inst
+	tcpInst.UnixSocket = ""
+	tcpInst.UnixSocketPath = ""
+	return append(lcs, listenerConfig{conf: &tcpConf, inst: tcpInst})
+}
+
+// checkMounts returns the socket mounts to dial when checking connections.
+// With FUSENoAutoconnect, FUSE mounts without a client connection are
+// excluded so that looking up a socket path never leads to a dial. Instances
+// with more than one listener are checked once.
+func (c *Client) checkMounts() []*socketMount {
+	if c.fuseDir == "" {
+		if !c.conf.DualListener {
+			return c.mnts
+		}
+		// With DualListener, an instance may have more than one mount. Check
+		// each instance once.
+		var (
+			mnts []*socketMount
+			seen = make(map[string]bool)
+		)
+		for _, m := range c.mnts {
+			if seen[m.inst] {
+				continue
+			}
+			seen[m.inst] = true
+			mnts = append(mnts, m)
+		}
+		return mnts
+	}
+	var mnts []*socketMount
+	for _, m := range c.fuseMounts() {
+		if c.conf.FUSENoAutoconnect && !m.accepted.Load() {
+			continue
+		}
+		mnts = append(mnts, m)
+	}
+	return mnts
+}
+
 // CheckConnections dials each registered instance and reports the number of
 // connections checked and any errors that may have occurred.
 func (c *Client) CheckConnections(ctx context.Context) (int, error) {
+	mnts := c.checkMounts()
+	res := c.checkConnections(ctx, mnts)
+	return len(mnts), checkConnectionsErr(mnts, res)
+}
+
+// CheckConnectionsByInstance dials each registered instance and reports the
+// outcome for each, keyed by the instance's short name, e.g.,
+// project.region.cluster.instance. A nil error means the dial succeeded.
+func (c *Client) CheckConnectionsByInstance(ctx context.Context) map[string]error {
+	return c.checkConnections(ctx, c.checkMounts())
+}
+
+// checkConnections dials the instance of each of mnts and reports the outcome
+// for each, keyed by the instance's short name.
+func (c *Client) checkConnections(ctx context.Context, mnts []*socketMount) map[string]error {
 	var (
-		wg    sync.WaitGroup
-		errCh = make(chan error, len(c.mnts))
-		mnts  = c.mnts
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		res = make(map[string]error, len(mnts))
 	)
-
-	if c.fuseDir != "" {
-		mnts = c.fuseMounts()
-	}
 	for _, mnt := range mnts {
 		wg.Add(1)
 		go func(m *socketMount) {
 			defer wg.Done()
 			conn, err := c.dialer.Dial(ctx, m.inst, m.dialOpts...)
+			m.recordDial(err)
+			mu.Lock()
+			res[m.instShort] = err
+			mu.Unlock()
 			if err != nil {
-				errCh <- err
 				return
 			}
 			cErr := conn.Close()
@@ -553,6 +1265,260 @@ func (c *Client) CheckConnections(ctx context.Context) (int, error) {
 		}(mnt)
 	}
 	wg.Wait()
+	return res
+}
+
+// checkConnectionsErr combines the failures in res into a MultiErr, ordered
+// as mnts, with each error prefixed by its instance. It returns nil if every
+// dial succeeded.
+func checkConnectionsErr(mnts []*socketMount, res map[string]error) error {
+	var mErr MultiErr
+	for _, m := range mnts {
+		if err := res[m.instShort]; err != nil {
+			mErr = append(mErr, &InstanceError{Instance: m.instShort, Err: err})
+		}
+	}
+	if len(mErr) > 0 {
+		return mErr
+	}
+	return nil
+}
+
+// RefreshHealth reports the outcome of the most recent certificate refreshes
+// for each instance the connector has refreshed, ordered by instance. It is
+// empty when the Client uses a dialer supplied by the caller.
+func (c *Client) RefreshHealth() []RefreshHealth {
+	return c.refresh.health()
+}
+
+// InstanceHealth reports the outcome of recent dials to an instance.
+type InstanceHealth struct {
+	// Instance is the short name of the instance, e.g.,
+	// project.region.cluster.instance.
+	Instance string
+	// LastSuccess is the time of the most recent successful dial. It is the
+	// zero time if no dial has succeeded.
+	LastSuccess time.Time
+	// LastFailure is the time of the most recent failed dial. It is the zero
+	// time if no dial has failed.
+	LastFailure time.Time
+	// LastError is the error of the most recent failed dial.
+	LastError error
+}
+
+// InstanceHealth reports the most recent successful and failed dial for
+// each instance, whether the dial was made for a client connection or by
+// CheckConnections.
+func (c *Client) InstanceHealth() []InstanceHealth {
+	mnts := c.mnts
+	if c.fuseDir != "" {
+		mnts = c.fuseMounts()
+	}
+	var (
+		hs  []InstanceHealth
+		idx = make(map[string]int)
+	)
+	for _, m := range mnts {
+		m.dialMu.Lock()
+		success, failure, err := m.lastSuccess, m.lastFailure, m.lastErr
+		m.dialMu.Unlock()
+
+		i, ok := idx[m.inst]
+		if !ok {
+			idx[m.inst] = len(hs)
+			hs = append(hs, InstanceHealth{
+				Instance:    m.instShort,
+				LastSuccess: success,
+				LastFailure: failure,
+				LastError:   err,
+			})
+			continue
+		}
+		// With DualListener, an instance has more than one mount. Report the
+		// most recent dials across its mounts.
+		if success.After(hs[i].LastSuccess) {
+			hs[i].LastSuccess = success
+		}
+		if failure.After(hs[i].LastFailure) {
+			hs[i].LastFailure = failure
+			hs[i].LastError = err
+		}
+	}
+	return hs
+}
+
+// InstanceError is an error that occurred for a specific instance.
+type InstanceError struct {
+	// Instance is the short name of the instance, e.g.,
+	// project.region.cluster.instance.
+	Instance string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *InstanceError) Error() string {
+	return fmt.Sprintf("[%s] %v", e.Instance, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *InstanceError) Unwrap() error {
+	return e.Err
+}
+
+// logConnectionTestResults reports the outcome of the connection test for
+// each of mnts, with the likely cause of any failure and a hint on how to fix
+// it.
+func (c *Client) logConnectionTestResults(mnts []*socketMount, res map[string]error) {
+	for _, m := range mnts {
+		err := res[m.instShort]
+		if err == nil {
+			c.logger.Infof("[%s] Connection test passed", m.instShort)
+			continue
+		}
+		cause, hint := classifyConnError(err)
+		c.logger.Errorf("Connection test failed (%v error): %v. %v",
+			cause, &InstanceError{Instance: m.instShort, Err: err}, hint)
+	}
+}
+
+// classifyConnError reports whether err was caused by authorization, a
+// missing instance, invalid configuration, or the network, along with a
+// hint on how to fix it.
+func classifyConnError(err error) (cause, hint string) {
+	var (
+		apiErr   *googleapi.Error
+		tokenErr *oauth2.RetrieveError
+		cfgErr   *errtype.ConfigError
+		netErr   net.Error
+	)
+	switch {
+	case errors.As(err, &tokenErr),
+		errors.As(err, &apiErr) && (apiErr.Code == http.StatusUnauthorized ||
+			apiErr.Code == http.StatusForbidden):
+		return "authorization", "Verify the credentials are valid and the IAM " +
+			"principal has the AlloyDB Client role (roles/alloydb.client)"
+	case errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound:
+		return "not found", "Verify the instance URI and that the instance exists"
+	case errors.As(err, &cfgErr):
+		return "configuration", "Verify the instance URI format and the " +
+			"instance configuration (e.g., public IP or PSC)"
+	case errors.As(err, &netErr), errors.Is(err, context.DeadlineExceeded):
+		return "network", "Verify the Proxy can reach the instance's IP " +
+			"address and port 5433 (e.g., VPC, firewall rules)"
+	default:
+		return "unknown", "Run with --debug-logs for more details"
+	}
+}
+
+// acquireConnSlot waits up to MaxConnectionsQueue for one of MaxConnections
+// slots to open up. It reports false if no slot opened up in time or the
+// Client was closed while waiting.
+func (c *Client) acquireConnSlot(cc *clientConn) bool {
+	select {
+	case c.connSlots <- struct{}{}:
+		return true
+	default:
+	}
+	cc.logger.Infof("max connections (%v) reached, waiting up to %v for a connection to close",
+		c.conf.MaxConnections, c.conf.MaxConnectionsQueue)
+	t := time.NewTimer(c.conf.MaxConnectionsQueue)
+	defer t.Stop()
+	select {
+	case c.connSlots <- struct{}{}:
+		return true
+	case <-t.C:
+		return false
+	case <-c.closed:
+		return false
+	}
+}
+
+// rateLimitWait is the longest a new connection may wait for the connection
+// rate limiter before it is refused.
+const rateLimitWait = time.Second
+
+// newConnLimiter returns a rate limiter for new connections, or nil if the
+// rate is unlimited.
+func newConnLimiter(conf *Config) *rate.Limiter {
+	if conf.NewConnectionRate <= 0 {
+		return nil
+	}
+	burst := conf.NewConnectionBurst
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(conf.NewConnectionRate), burst)
+}
+
+// allowConn waits for the connection rate limiter for s, if any. It reports
+// false if the connection would wait longer than rateLimitWait.
+func (c *Client) allowConn(ctx context.Context, s *socketMount) bool {
+	l := c.limiter
+	if s.limiter != nil {
+		l = s.limiter
+	}
+	if l == nil {
+		return true
+	}
+	r := l.Reserve()
+	d := r.Delay()
+	if d > rateLimitWait {
+		r.Cancel()
+		return false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		r.Cancel()
+		return false
+	}
+}
+
+// ProbeConnections opens a TCP connection to the server-side proxy of each
+// registered instance without performing a TLS handshake or authentication.
+// Instances that have not been dialed yet have no known address and are
+// dialed in full instead. ProbeConnections reports the number of instances
+// probed and any errors that may have occurred. When ReadinessTCPProbe is not
+// enabled, ProbeConnections does nothing.
+func (c *Client) ProbeConnections(ctx context.Context) (int, error) {
+	if !c.conf.ReadinessTCPProbe {
+		return 0, nil
+	}
+	var (
+		wg    sync.WaitGroup
+		mnts  = c.checkMounts()
+		errCh = make(chan error, len(mnts))
+	)
+	for _, mnt := range mnts {
+		wg.Add(1)
+		go func(m *socketMount) {
+			defer wg.Done()
+			var (
+				conn net.Conn
+				err  error
+			)
+			if addr := m.backend(); addr != "" {
+				conn, err = netproxy.Dial(ctx, m.tcpNetwork("tcp"), addr)
+			} else {
+				conn, err = c.dialer.Dial(ctx, m.inst, m.dialOpts...)
+			}
+			if err != nil {
+				errCh <- &InstanceError{Instance: m.instShort, Err: fmt.Errorf("probe failed: %w", err)}
+				return
+			}
+			m.recordBackend(conn)
+			if cErr := conn.Close(); cErr != nil {
+				c.logger.Errorf(
+					"connection probe failed to close connection for %v: %v",
+					m.inst, cErr,
+				)
+			}
+		}(mnt)
+	}
+	wg.Wait()
 
 	var mErr MultiErr
 	for i := 0; i < len(mnts); i++ {
@@ -576,25 +1542,128 @@ func (c *Client) ConnCount() (uint64, uint64) {
 	return atomic.LoadUint64(&c.connCount), c.conf.MaxConnections
 }
 
+// Addrs returns the address of each listener the Client has bound, in the
+// order the instances were configured. FUSE mounts are not included.
+func (c *Client) Addrs() []net.Addr {
+	var addrs []net.Addr
+	for _, m := range c.mnts {
+		addrs = append(addrs, m.Addr())
+	}
+	return addrs
+}
+
+// ReadinessConnLimit returns the number of open connections above which the
+// Client is not ready to accept more connections, as configured by
+// ReadinessMaxConnectionsPct. Returns 0 when there is no such limit.
+func (c *Client) ReadinessConnLimit() uint64 {
+	if c.conf.MaxConnections == 0 || c.conf.ReadinessMaxConnectionsPct == 0 {
+		return 0
+	}
+	return c.conf.MaxConnections * c.conf.ReadinessMaxConnectionsPct / 100
+}
+
+// Start begins accepting connections on all listeners. Calling Start is only
+// necessary when the Client is configured with ManualStart, or after a call
+// to Stop.
+func (c *Client) Start() {
+	c.acceptMu.Lock()
+	defer c.acceptMu.Unlock()
+	select {
+	case <-c.acceptCh:
+		// The Client is already accepting connections.
+	default:
+		close(c.acceptCh)
+	}
+}
+
+// Stop stops accepting new connections on all listeners until Start is called
+// again. Listeners remain bound and open connections are unaffected.
+func (c *Client) Stop() {
+	c.acceptMu.Lock()
+	defer c.acceptMu.Unlock()
+	select {
+	case <-c.acceptCh:
+		c.acceptCh = make(chan struct{})
+	default:
+		// The Client is already stopped.
+	}
+}
+
+// ReloadCredentials replaces the Client's dialer with one that re-reads the
+// configured credentials, so that subsequent dials use the new credentials.
+// Open connections are unaffected. ReloadCredentials requires a Client
+// configured with ReloadCredentials and without a caller-provided dialer.
+func (c *Client) ReloadCredentials() error {
+	if c.reloader == nil {
+		return errors.New("credentials reload is not enabled")
+	}
+	if err := c.reloader.reload(); err != nil {
+		return fmt.Errorf("failed to reload credentials: %v", err)
+	}
+	c.logger.Infof("Reloaded credentials")
+	c.logPrincipal()
+	return nil
+}
+
+// logPrincipal logs the IAM principal in the background, until the lookup
+// finishes or Close is called.
+func (c *Client) logPrincipal() {
+	c.principalWG.Add(1)
+	go func() {
+		defer c.principalWG.Done()
+		logPrincipal(c.principalCtx, c.logger, *c.conf)
+	}()
+}
+
+// accepting returns a channel that is closed when the Client is accepting new
+// connections.
+func (c *Client) accepting() <-chan struct{} {
+	c.acceptMu.Lock()
+	defer c.acceptMu.Unlock()
+	return c.acceptCh
+}
+
+// Ready returns a channel that is closed once Serve is serving on all
+// listeners. It is an alternative to the notify callback of Serve.
+func (c *Client) Ready() <-chan struct{} {
+	return c.ready
+}
+
 // Serve starts proxying connections for all configured instances using the
-// associated socket.
+// associated socket. Once all listeners are serving, Serve closes the channel
+// returned by Ready and calls notify, if it is not nil.
 func (c *Client) Serve(ctx context.Context, notify func()) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	userNotify := notify
+	notify = func() {
+		c.readyOnce.Do(func() { close(c.ready) })
+		if userNotify != nil {
+			userNotify()
+		}
+	}
+
 	if c.fuseDir != "" {
 		return c.serveFuse(ctx, notify)
 	}
 
 	if c.conf.RunConnectionTest {
 		c.logger.Infof("Connection test started")
-		if _, err := c.CheckConnections(ctx); err != nil {
+		mnts := c.checkMounts()
+		res := c.checkConnections(ctx, mnts)
+		c.logConnectionTestResults(mnts, res)
+		if err := checkConnectionsErr(mnts, res); err != nil {
 			c.logger.Errorf("Connection test failed")
 			return err
 		}
 		c.logger.Infof("Connection test passed")
 	}
 
+	if c.conf.ManualStart {
+		c.logger.Infof("Listeners are bound. Waiting for start before accepting connections")
+	}
+
 	exitCh := make(chan error)
 	for _, m := range c.mnts {
 		go func(mnt *socketMount) {
@@ -614,7 +1683,12 @@ func (c *Client) Serve(ctx context.Context, notify func()) error {
 		}(m)
 	}
 	notify()
-	return <-exitCh
+	select {
+	case err := <-exitCh:
+		return err
+	case <-c.closed:
+		return nil
+	}
 }
 
 // MultiErr is a group of errors wrapped into one.
@@ -633,10 +1707,25 @@ func (m MultiErr) Error() string {
 	return strings.Join(errs, ", ")
 }
 
+// Unwrap returns the contained errors so errors.Is and errors.As can inspect
+// each of them.
+func (m MultiErr) Unwrap() []error {
+	return m
+}
+
 // Close stops the dialer, closes any open FUSE mounts and any open listeners,
 // and optionally waits for all connections to close before exiting.
 func (c *Client) Close() error {
+	// Signal the shutdown before closing any listeners, so accept loops do
+	// not report the closed listeners as errors.
+	c.closeOnce.Do(func() { close(c.closed) })
+	if c.stopPrincipal != nil {
+		c.stopPrincipal()
+	}
+	c.principalWG.Wait()
 	mnts := c.mnts
+	// Close the audit file last, once any open connections have closed.
+	defer c.audit.close()
 
 	var mErr MultiErr
 
@@ -648,12 +1737,17 @@ func (c *Client) Close() error {
 	}
 
 	// First, close all open socket listeners to prevent additional connections.
-	for _, m := range mnts {
-		err := m.Close()
-		if err != nil {
-			mErr = append(mErr, err)
+	if c.listenersClosed.CompareAndSwap(false, true) {
+		for _, m := range mnts {
+			err := m.Close()
+			if err != nil {
+				mErr = append(mErr, err)
+			}
 		}
 	}
+	// Release any accept loops waiting for Start, so they observe the closed
+	// listeners and exit.
+	c.Start()
 	if c.fuseDir != "" {
 		c.waitForFUSEMounts()
 	}
@@ -668,13 +1762,34 @@ func (c *Client) Close() error {
 		}
 		return nil
 	}
-	timeout := time.After(c.conf.WaitOnClose)
+	var (
+		deadline = time.Now().Add(c.conf.WaitOnClose)
+		timeout  = time.After(c.conf.WaitOnClose)
+		lastLog  = time.Now()
+		// initial is the number of connections open when waiting began.
+		initial = atomic.LoadUint64(&c.connCount)
+	)
+	if initial > 0 {
+		c.logger.Infof("Waiting up to %v for %d open connection(s) to close",
+			c.conf.WaitOnClose, initial)
+	}
 	t := time.NewTicker(100 * time.Millisecond)
 	defer t.Stop()
 	for {
 		select {
 		case <-t.C:
-			if atomic.LoadUint64(&c.connCount) > 0 {
+			open := atomic.LoadUint64(&c.connCount)
+			recordDrainingConnections(context.Background(), open)
+			if open > 0 {
+				// Report progress about once a second to explain a slow
+				// shutdown without flooding the logs.
+				if c.conf.DebugLogs && time.Since(lastLog) >= time.Second {
+					c.logger.Debugf(
+						"Waiting for %d open connection(s) to close (%v remaining)",
+						open, time.Until(deadline).Round(time.Second),
+					)
+					lastLog = time.Now()
+				}
 				continue
 			}
 		case <-timeout:
@@ -682,6 +1797,13 @@ func (c *Client) Close() error {
 		break
 	}
 	open := atomic.LoadUint64(&c.connCount)
+	switch {
+	case open > 0:
+		c.logger.Infof("Stopped waiting after %v with %d connection(s) still open",
+			c.conf.WaitOnClose, open)
+	case initial > 0:
+		c.logger.Infof("All open connections closed")
+	}
 	if open > 0 {
 		mErr = append(mErr, fmt.Errorf("%d connection(s) still open after waiting %v", open, c.conf.WaitOnClose))
 	}
@@ -691,10 +1813,42 @@ func (c *Client) Close() error {
 	return nil
 }
 
+// CloseListeners closes all socket listeners so that the Client refuses new
+// connections, while open connections are unaffected. Close must still be
+// called to stop the dialer and wait for open connections. CloseListeners is
+// not supported with FUSE.
+func (c *Client) CloseListeners() error {
+	if c.fuseDir != "" {
+		return errors.New("closing listeners is not supported with FUSE")
+	}
+	if !c.listenersClosed.CompareAndSwap(false, true) {
+		return nil
+	}
+	c.closeOnce.Do(func() { close(c.closed) })
+	var mErr MultiErr
+	for _, m := range c.mnts {
+		if err := m.Close(); err != nil {
+			mErr = append(mErr, err)
+		}
+	}
+	// Release any accept loops waiting for Start, so they observe the closed
+	// listeners and exit.
+	c.Start()
+	if len(mErr) > 0 {
+		return mErr
+	}
+	return nil
+}
+
 // serveSocketMount persistently listens to the socketMounts listener and proxies connections to a
 // given AlloyDB instance.
-func (c *Client) serveSocketMount(_ context.Context, s *socketMount) error {
+func (c *Client) serveSocketMount(ctx context.Context, s *socketMount) error {
 	for {
+		select {
+		case <-c.accepting():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 		cConn, err := s.Accept()
 		if err != nil {
 			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
@@ -703,11 +1857,54 @@ func (c *Client) serveSocketMount(_ context.Context, s *socketMount) error {
 				time.Sleep(10 * time.Millisecond)
 				continue
 			}
+			select {
+			case <-c.closed:
+				// Close closed the listener, which is not a failure.
+				return nil
+			default:
+			}
 			return err
 		}
+		s.accepted.Store(true)
+		select {
+		case <-c.accepting():
+		default:
+			// The Client was stopped while waiting for a connection.
+			c.logger.Infof("[%s] not accepting connections, closing connection from %s",
+				s.instShort, cConn.RemoteAddr())
+			_ = cConn.Close()
+			continue
+		}
+		if c.clientTLS != nil {
+			cConn = tls.Server(cConn, c.clientTLS)
+		}
 		// handle the connection in a separate goroutine
 		go func() {
-			c.logger.Infof("[%s] accepted connection from %s\n", s.instShort, cConn.RemoteAddr())
+			cc := c.newClientConn(s.instShort)
+			cc.logger.Infof("accepted connection from %s\n", cConn.RemoteAddr())
+
+			refuseMaxConnections := func() {
+				cc.logger.Infof("max connections (%v) exceeded, refusing new connection", c.conf.MaxConnections)
+				recordConnectionRefused(context.Background(), s.inst, s.instShort, reasonMaxConnections)
+				if c.conf.FriendlyMaxConnError {
+					msg := fmt.Sprintf("proxy connection limit reached (max = %v)", c.conf.MaxConnections)
+					err := writePostgresError(cConn, pgTooManyConnections, msg)
+					if err != nil && c.conf.DebugLogs {
+						cc.logger.Debugf("failed to send error to client: %v", err)
+					}
+				}
+				_ = cConn.Close()
+			}
+
+			// With a queue, wait for a slot to open up before counting the
+			// connection.
+			if c.connSlots != nil {
+				if !c.acquireConnSlot(cc) {
+					refuseMaxConnections()
+					return
+				}
+				defer func() { <-c.connSlots }()
+			}
 
 			// A client has established a connection to the local socket. Before
 			// we initiate a connection to the AlloyDB backend, increment the
@@ -717,22 +1914,47 @@ func (c *Client) serveSocketMount(_ context.Context, s *socketMount) error {
 			defer atomic.AddUint64(&c.connCount, ^uint64(0))
 
 			if c.conf.MaxConnections > 0 && count > c.conf.MaxConnections {
-				c.logger.Infof("max connections (%v) exceeded, refusing new connection", c.conf.MaxConnections)
-				_ = cConn.Close()
+				refuseMaxConnections()
 				return
 			}
 
-			// give a max of 30 seconds to connect to the instance
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			ctx, cancel := context.WithTimeout(context.Background(), s.dialTimeout)
 			defer cancel()
 
+			if !c.allowConn(ctx, s) {
+				cc.logger.Infof("new connection rate (%v/s) exceeded, refusing new connection", c.conf.NewConnectionRate)
+				recordConnectionRefused(context.Background(), s.inst, s.instShort, reasonRateLimit)
+				_ = cConn.Close()
+				return
+			}
+
+			// When client TLS is enabled, complete the handshake before
+			// connecting to the instance, so unencrypted clients are refused.
+			if tc, ok := cConn.(*tls.Conn); ok {
+				if err := tc.HandshakeContext(ctx); err != nil {
+					cc.logger.Errorf("client TLS handshake failed: %v", err)
+					_ = cConn.Close()
+					return
+				}
+				if certs := tc.ConnectionState().PeerCertificates; len(certs) > 0 {
+					cc.logger.Infof("client certificate verified for %v", certs[0].Subject)
+				}
+			}
+
+			if c.conf.DebugLogs {
+				cc.logger.Debugf("dialing instance using %s", s.ipType)
+			}
+			ctx, endSpan := s.startDialSpan(ctx)
 			sConn, err := c.dialer.Dial(ctx, s.inst, s.dialOpts...)
+			endSpan()
+			s.recordDial(err)
 			if err != nil {
-				c.logger.Errorf("[%s] failed to connect to instance: %v\n", s.instShort, err)
+				cc.logger.Errorf("failed to connect to instance: %v\n", err)
 				cConn.Close()
 				return
 			}
-			c.proxyConn(s.instShort, cConn, sConn)
+			s.recordBackend(sConn)
+			c.proxyConn(cc, cConn, sConn)
 		}()
 	}
 }
@@ -743,21 +1965,126 @@ type socketMount struct {
 	instShort string
 	listener  net.Listener
 	dialOpts  []alloydbconn.DialOption
+	// ipType is the instance endpoint used when dialing, e.g., "public IP".
+	ipType string
+	// disableIPv6 restricts connections to the instance to IPv4.
+	disableIPv6 bool
+	// limiter limits the rate of new connections to the instance. When nil,
+	// the Client's limiter applies.
+	limiter *rate.Limiter
+	// dialTimeout is the maximum time to wait when connecting to the
+	// instance.
+	dialTimeout time.Duration
+	// sampler decides whether to trace a connection to the instance. When
+	// nil, the global sampler applies.
+	sampler trace.Sampler
+
+	// accepted reports whether a client has connected to the mount.
+	accepted atomic.Bool
+
+	// backendMu protects backendAddr.
+	backendMu sync.Mutex
+	// backendAddr is the host:port of the instance's server-side proxy as of
+	// the most recent dial. It is empty until the instance is first dialed.
+	backendAddr string
+
+	// dialMu protects lastSuccess, lastFailure, and lastErr.
+	dialMu sync.Mutex
+	// lastSuccess is the time of the most recent successful dial.
+	lastSuccess time.Time
+	// lastFailure is the time of the most recent failed dial.
+	lastFailure time.Time
+	// lastErr is the error of the most recent failed dial.
+	lastErr error
+}
+
+// startDialSpan starts a span for connecting to the instance when the mount
+// has its own sampler. The connector's spans become its children and, with a
+// sampler that follows the parent, share its sampling decision. The returned
+// function ends the span.
+func (s *socketMount) startDialSpan(ctx context.Context) (context.Context, func()) {
+	if s.sampler == nil {
+		return ctx, func() {}
+	}
+	ctx, span := trace.StartSpan(ctx, dialSpanName, trace.WithSampler(s.sampler))
+	span.AddAttributes(trace.StringAttribute("alloydb_instance", s.instShort))
+	return ctx, span.End
+}
+
+// recordDial records the outcome of a dial to the mount's instance.
+func (s *socketMount) recordDial(err error) {
+	now := time.Now()
+	s.dialMu.Lock()
+	defer s.dialMu.Unlock()
+	if err != nil {
+		s.lastFailure = now
+		s.lastErr = err
+		return
+	}
+	s.lastSuccess = now
+}
+
+// dialBackend connects to the instance's server-side proxy and records its
+// address for use by readiness probes.
+func (s *socketMount) dialBackend(ctx context.Context, network, addr string) (net.Conn, error) {
+	s.setBackend(addr)
+	return netproxy.Dial(ctx, s.tcpNetwork(network), addr)
+}
+
+// setBackend records addr as the address of the instance's server-side
+// proxy.
+func (s *socketMount) setBackend(addr string) {
+	s.backendMu.Lock()
+	defer s.backendMu.Unlock()
+	s.backendAddr = addr
+}
+
+// recordBackend records the remote address of conn, a connection to the
+// instance, when it is a TCP address.
+func (s *socketMount) recordBackend(conn net.Conn) {
+	if a, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		s.setBackend(a.String())
+	}
+}
+
+// tcpNetwork returns the network to use in place of network when connecting
+// to the instance: "tcp4" in place of "tcp" when IPv6 is disabled.
+func (s *socketMount) tcpNetwork(network string) string {
+	if s.disableIPv6 && network == "tcp" {
+		return "tcp4"
+	}
+	return network
+}
+
+// backend returns the most recently dialed address of the instance's
+// server-side proxy.
+func (s *socketMount) backend() string {
+	s.backendMu.Lock()
+	defer s.backendMu.Unlock()
+	return s.backendAddr
 }
 
 func newSocketMount(ctx context.Context, conf *Config, pc *portConfig, inst InstanceConnConfig) (*socketMount, error) {
 	shortInst, err := ShortInstURI(inst.Name)
 	if err != nil {
-		return nil, err
+		if !conf.SkipURIValidation {
+			return nil, err
+		}
+		shortInst = fallbackShortName(inst.Name)
 	}
 
 	var (
-		// network is one of "tcp" or "unix"
+		// network is one of "tcp", "tcp4", "tcp6", "unix", or "pipe"
 		network string
-		// address is either a TCP host port, or a Unix socket
+		// address is either a TCP host port, a Unix socket, or a named pipe
 		address string
 	)
 
+	// IF
+	//   named pipes are enabled AND
+	//   the instance does NOT configure its own address, port, or Unix socket
+	// use a named pipe in place of a TCP listener.
+	//
 	// IF
 	//   a global Unix socket directory is NOT set AND
 	//   an instance-level Unix socket is NOT set
@@ -768,9 +2095,19 @@ func newSocketMount(ctx context.Context, conf *Config, pc *portConfig, inst Inst
 	//   instance)
 	// use a TCP listener.
 	// Otherwise, use a Unix socket.
-	if (conf.UnixSocket == "" && inst.UnixSocket == "" && inst.UnixSocketPath == "") ||
+	if conf.NamedPipe && conf.UnixSocket == "" && inst.UnixSocket == "" &&
+		inst.UnixSocketPath == "" && inst.Addr == "" && inst.Port == 0 {
+		network = "pipe"
+		address = NamedPipeName(shortInst)
+	} else if (conf.UnixSocket == "" && inst.UnixSocket == "" && inst.UnixSocketPath == "") ||
 		(inst.Addr != "" || inst.Port != 0) {
 		network = "tcp"
+		switch {
+		case conf.DisableIPv6:
+			network = "tcp4"
+		case conf.ListenNetwork != "":
+			network = conf.ListenNetwork
+		}
 
 		a := conf.Addr
 		if inst.Addr != "" {
@@ -788,16 +2125,36 @@ func newSocketMount(ctx context.Context, conf *Config, pc *portConfig, inst Inst
 		address = net.JoinHostPort(a, fmt.Sprint(np))
 	} else {
 		network = "unix"
-		address, err = newUnixSocketMount(inst, conf.UnixSocket, true)
+		address, err = newUnixSocketMount(
+			inst, conf.UnixSocket, postgresSocketName(conf.FUSESocketSuffix),
+			conf.DisableInstanceURILowercasing, conf.SkipURIValidation,
+		)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	lc := net.ListenConfig{KeepAlive: 30 * time.Second}
-	ln, err := lc.Listen(ctx, network, address)
+	var ln net.Listener
+	backoff := bindRetryBackoff
+	for attempt := 0; ; attempt++ {
+		if network == "pipe" {
+			ln, err = listenPipe(address)
+		} else {
+			lc := net.ListenConfig{KeepAlive: 30 * time.Second}
+			ln, err = lc.Listen(ctx, network, address)
+		}
+		if err == nil || attempt >= conf.BindRetries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBindRetryBackoff)
+	}
 	if err != nil {
-		return nil, err
+		return nil, bindError(network, address, err)
 	}
 	// Change file permisions to allow access for user, group, and other.
 	if network == "unix" {
@@ -807,17 +2164,80 @@ func newSocketMount(ctx context.Context, conf *Config, pc *portConfig, inst Inst
 	}
 	opts := dialOptions(*conf, inst)
 	m := &socketMount{
-		inst:      inst.Name,
-		instShort: shortInst,
-		listener:  ln,
-		dialOpts:  opts,
+		inst:        inst.Name,
+		instShort:   shortInst,
+		listener:    ln,
+		dialOpts:    opts,
+		ipType:      ipType(*conf, inst),
+		disableIPv6: conf.DisableIPv6,
+		dialTimeout: dialTimeout(*conf, inst),
+		sampler:     traceSampler(inst),
+	}
+	if conf.NewConnectionRatePerInstance {
+		m.limiter = newConnLimiter(conf)
+	}
+	// Replacing the connector's dial function overrides any the dialer was
+	// created with, so only do so when the probe or IPv4 requires it.
+	if conf.ReadinessTCPProbe || conf.DisableIPv6 {
+		m.dialOpts = append(m.dialOpts, alloydbconn.WithOneOffDialFunc(m.dialBackend))
 	}
 	return m, nil
 }
 
+const (
+	// bindRetryBackoff is the wait before the first retry to bind a
+	// listener. The wait doubles with each retry, up to
+	// maxBindRetryBackoff.
+	bindRetryBackoff    = 100 * time.Millisecond
+	maxBindRetryBackoff = 2 * time.Second
+)
+
+// bindError adds a remediation hint to err for the common reasons a TCP
+// listener fails to bind.
+func bindError(network, address string, err error) error {
+	if !strings.HasPrefix(network, "tcp") {
+		return err
+	}
+	_, p, sErr := net.SplitHostPort(address)
+	if sErr != nil {
+		return err
+	}
+	port, _ := strconv.Atoi(p)
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE):
+		return fmt.Errorf(
+			"port %v is already in use; choose another with --port "+
+				"or the port query parameter: %w", port, err,
+		)
+	case errors.Is(err, syscall.EACCES) && port > 0 && port < 1024:
+		return fmt.Errorf(
+			"permission denied binding to privileged port %v; "+
+				"run as root or use a port above 1023: %w", port, err,
+		)
+	default:
+		return err
+	}
+}
+
+// NamedPipeName returns the Windows named pipe for the instance with the
+// provided short name, e.g., \\.\pipe\alloydb-project.region.cluster.instance.
+func NamedPipeName(shortInst string) string {
+	return `\\.\pipe\alloydb-` + shortInst
+}
+
+// postgresSocketName returns the name of the Postgres Unix socket with the
+// provided suffix, or .s.PGSQL.5432 when suffix is empty.
+func postgresSocketName(suffix string) string {
+	if suffix == "" {
+		suffix = "5432"
+	}
+	return ".s.PGSQL." + suffix
+}
+
 // newUnixSocketMount parses the configuration and returns the path to the unix
-// socket, or an error if that path is not valid.
-func newUnixSocketMount(inst InstanceConnConfig, unixSocketDir string, postgres bool) (string, error) {
+// socket, or an error if that path is not valid. When pgSocket is set, the
+// returned path is a socket of that name inside a directory for the instance.
+func newUnixSocketMount(inst InstanceConnConfig, unixSocketDir, pgSocket string, preserveCase, skipURIValidation bool) (string, error) {
 	var (
 		// the path to the unix socket
 		address string
@@ -829,7 +2249,7 @@ func newUnixSocketMount(inst InstanceConnConfig, unixSocketDir string, postgres
 		// When UnixSocketPath is set
 		address = inst.UnixSocketPath
 		// If UnixSocketPath ends .s.PGSQL.5432, remove it for consistency
-		if postgres && path.Base(address) == ".s.PGSQL.5432" {
+		if pgSocket != "" && path.Base(address) == pgSocket {
 			address = path.Dir(address)
 		}
 		dir = path.Dir(address)
@@ -839,9 +2259,16 @@ func newUnixSocketMount(inst InstanceConnConfig, unixSocketDir string, postgres
 		if dir == "" {
 			dir = inst.UnixSocket
 		}
-		address, err = UnixSocketDir(dir, inst.Name)
+		address, err = unixSocketName(dir, inst.Name, preserveCase)
 		if err != nil {
-			return "", err
+			if !skipURIValidation {
+				return "", err
+			}
+			name := inst.Name
+			if !preserveCase {
+				name = strings.ToLower(name)
+			}
+			address = filepath.Join(dir, fallbackShortName(name))
 		}
 	}
 	// if base directory does not exist, fail
@@ -849,16 +2276,16 @@ func newUnixSocketMount(inst InstanceConnConfig, unixSocketDir string, postgres
 		return "", err
 	}
 	// When setting up a listener for Postgres, create address as a
-	// directory, and use the Postgres-specific socket name
+	// directory, and use the Postgres-specific socket name, e.g.,
 	// .s.PGSQL.5432.
-	if postgres {
+	if pgSocket != "" {
 		// Make the directory only if it hasn't already been created.
 		if _, err := os.Stat(address); err != nil {
 			if err = os.Mkdir(address, 0777); err != nil {
 				return "", err
 			}
 		}
-		address = UnixAddress(address, ".s.PGSQL.5432")
+		address = UnixAddress(address, pgSocket)
 	}
 	return address, nil
 }
@@ -876,8 +2303,187 @@ func (s *socketMount) Close() error {
 	return s.listener.Close()
 }
 
+// clientConn holds the state of a single client connection for the duration
+// of its lifecycle.
+type clientConn struct {
+	// id is a short random identifier that correlates the log messages of a
+	// connection.
+	id string
+	// inst is the short name of the instance.
+	inst string
+	// logger reports messages with the instance name and connection ID.
+	logger alloydb.Logger
+}
+
+// fieldLogger is implemented by loggers that support structured fields.
+type fieldLogger interface {
+	With(key, value string) alloydb.Logger
+}
+
+// newClientConn creates the state for a newly accepted connection to inst.
+// Structured loggers report the connection ID as a connectionId field. Other
+// loggers include it in each message. When inst is excluded by LogInstances,
+// only errors are logged.
+func (c *Client) newClientConn(inst string) *clientConn {
+	b := make([]byte, 4)
+	// crypto/rand.Read does not fail on supported platforms.
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	l := c.logger
+	prefix := fmt.Sprintf("[%s] [conn=%s] ", inst, id)
+	if fl, ok := l.(fieldLogger); ok {
+		l = fl.With("connectionId", id)
+		prefix = fmt.Sprintf("[%s] ", inst)
+	}
+	if c.logInstances != nil && !c.logInstances[inst] {
+		l = log.NewErrorLogger(l)
+	}
+	return &clientConn{
+		id:     id,
+		inst:   inst,
+		logger: log.NewPrefixLogger(l, prefix),
+	}
+}
+
+const (
+	// pgTooManyConnections is the Postgres error code too_many_connections.
+	pgTooManyConnections = "53300"
+	// pgStartupTimeout is the longest the Proxy waits for a client's startup
+	// message before giving up on sending an error.
+	pgStartupTimeout = 5 * time.Second
+)
+
+// writePostgresError reads the client's startup message and responds with a
+// fatal Postgres ErrorResponse with the provided code and message, so that
+// clients such as psql report the message instead of a connection reset.
+// Requests for SSL or GSS encryption are declined first.
+func writePostgresError(conn net.Conn, code, msg string) error {
+	if err := conn.SetDeadline(time.Now().Add(pgStartupTimeout)); err != nil {
+		return err
+	}
+	b := pgproto3.NewBackend(conn, conn)
+	for {
+		m, err := b.ReceiveStartupMessage()
+		if err != nil {
+			return err
+		}
+		switch m.(type) {
+		case *pgproto3.SSLRequest, *pgproto3.GSSEncRequest:
+			// Decline encryption and wait for the startup message.
+			if _, err := conn.Write([]byte("N")); err != nil {
+				return err
+			}
+		case *pgproto3.StartupMessage:
+			b.Send(&pgproto3.ErrorResponse{
+				Severity:            "FATAL",
+				SeverityUnlocalized: "FATAL",
+				Code:                code,
+				Message:             msg,
+			})
+			return b.Flush()
+		default:
+			// Cancel requests expect no response.
+			return nil
+		}
+	}
+}
+
+const (
+	// pgSSLRequestCode and pgGSSEncRequestCode identify a client's request
+	// for SSL or GSS encryption in place of a startup message.
+	pgSSLRequestCode    = 80877103
+	pgGSSEncRequestCode = 80877104
+	// pgMaxStartupPacket is the largest startup packet Postgres accepts.
+	pgMaxStartupPacket = 10000
+	// pgApplicationName is the startup parameter naming the client
+	// application.
+	pgApplicationName = "application_name"
+)
+
+// readStartupPacket reads a single length-prefixed startup packet from r,
+// including the length, without reading past the end of the packet.
+func readStartupPacket(r io.Reader) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n < 8 || n > pgMaxStartupPacket {
+		return nil, fmt.Errorf("invalid startup packet length %d", n)
+	}
+	p := make([]byte, n)
+	copy(p, hdr[:])
+	if _, err := io.ReadFull(r, p[len(hdr):]); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// appendApplicationName returns the application name name with suffix
+// appended.
+func appendApplicationName(name, suffix string) string {
+	if name == "" {
+		return suffix
+	}
+	return name + " (" + suffix + ")"
+}
+
+// forwardStartupMessage copies the client's startup message to the server
+// with suffix appended to its application_name parameter. Requests for SSL or
+// GSS encryption are forwarded along with the server's response. When the
+// server accepts encryption, the startup message that follows is encrypted
+// and is left to be copied unchanged, as are cancel requests. The bytes
+// written to the server and to the client are added to sent and received.
+func forwardStartupMessage(client, server net.Conn, suffix string, sent, received *atomic.Uint64) error {
+	for {
+		p, err := readStartupPacket(client)
+		if err != nil {
+			return err
+		}
+		code := binary.BigEndian.Uint32(p[4:8])
+		if code == pgproto3.ProtocolVersionNumber {
+			var m pgproto3.StartupMessage
+			if err := m.Decode(p[4:]); err != nil {
+				return err
+			}
+			m.Parameters[pgApplicationName] = appendApplicationName(m.Parameters[pgApplicationName], suffix)
+			if p, err = m.Encode(nil); err != nil {
+				return err
+			}
+		}
+		n, err := server.Write(p)
+		sent.Add(uint64(n))
+		if err != nil {
+			return err
+		}
+		if code != pgSSLRequestCode && code != pgGSSEncRequestCode {
+			return nil
+		}
+		// The server responds with a single byte, N to decline encryption.
+		var resp [1]byte
+		if _, err := io.ReadFull(server, resp[:]); err != nil {
+			return err
+		}
+		n, err = client.Write(resp[:])
+		received.Add(uint64(n))
+		if err != nil {
+			return err
+		}
+		if resp[0] != 'N' {
+			return nil
+		}
+	}
+}
+
 // proxyConn sets up a bidirectional copy between two open connections
-func (c *Client) proxyConn(inst string, client, server net.Conn) {
+func (c *Client) proxyConn(cc *clientConn, client, server net.Conn) {
+	remoteAddr := client.RemoteAddr().String()
+	c.auditConn(cc, auditConnect, remoteAddr, 0, 0)
+	// sent and received count the bytes copied to the instance and to the
+	// client.
+	var sent, received atomic.Uint64
+
 	// only allow the first side to give an error for terminating a connection
 	var o sync.Once
 	cleanup := func(errDesc string, isErr bool) {
@@ -885,13 +2491,40 @@ func (c *Client) proxyConn(inst string, client, server net.Conn) {
 			client.Close()
 			server.Close()
 			if isErr {
-				c.logger.Errorf(errDesc)
+				cc.logger.Errorf(errDesc)
 			} else {
-				c.logger.Infof(errDesc)
+				cc.logger.Infof(errDesc)
 			}
+			c.auditConn(cc, auditClose, remoteAddr, sent.Load(), received.Load())
 		})
 	}
 
+	if suffix := c.conf.AppendApplicationName; suffix != "" {
+		err := forwardStartupMessage(client, server, suffix, &sent, &received)
+		switch {
+		case err == io.EOF:
+			cleanup("client closed the connection", false)
+			return
+		case err != nil:
+			cleanup(fmt.Sprintf("connection aborted - error forwarding startup message: %v", err), true)
+			return
+		}
+	}
+
+	// expired is set once the connection exceeds MaxConnectionLifetime.
+	var expired atomic.Bool
+	if d := c.conf.MaxConnectionLifetime; d > 0 {
+		t := time.AfterFunc(d, func() {
+			expired.Store(true)
+			// Interrupt blocked reads on both sides. Any write in progress
+			// completes before the connection is closed.
+			_ = client.SetReadDeadline(time.Now())
+			_ = server.SetReadDeadline(time.Now())
+		})
+		defer t.Stop()
+	}
+	expiredDesc := fmt.Sprintf("closing connection after max connection lifetime (%v)", c.conf.MaxConnectionLifetime)
+
 	// copy bytes from client to server
 	go func() {
 		buf := make([]byte, 8*1024) // 8kb
@@ -899,20 +2532,25 @@ func (c *Client) proxyConn(inst string, client, server net.Conn) {
 			n, cErr := client.Read(buf)
 			var sErr error
 			if n > 0 {
-				_, sErr = server.Write(buf[:n])
+				var w int
+				w, sErr = server.Write(buf[:n])
+				sent.Add(uint64(w))
 			}
 			switch {
+			case expired.Load():
+				cleanup(expiredDesc, false)
+				return
 			case cErr == io.EOF:
-				cleanup(fmt.Sprintf("[%s] client closed the connection", inst), false)
+				cleanup("client closed the connection", false)
 				return
 			case cErr != nil:
-				cleanup(fmt.Sprintf("[%s] connection aborted - error reading from client: %v", inst, cErr), true)
+				cleanup(fmt.Sprintf("connection aborted - error reading from client: %v", cErr), true)
 				return
 			case sErr == io.EOF:
-				cleanup(fmt.Sprintf("[%s] instance closed the connection", inst), false)
+				cleanup("instance closed the connection", false)
 				return
 			case sErr != nil:
-				cleanup(fmt.Sprintf("[%s] connection aborted - error writing to instance: %v", inst, cErr), true)
+				cleanup(fmt.Sprintf("connection aborted - error writing to instance: %v", cErr), true)
 				return
 			}
 		}
@@ -924,20 +2562,25 @@ func (c *Client) proxyConn(inst string, client, server net.Conn) {
 		n, sErr := server.Read(buf)
 		var cErr error
 		if n > 0 {
-			_, cErr = client.Write(buf[:n])
+			var w int
+			w, cErr = client.Write(buf[:n])
+			received.Add(uint64(w))
 		}
 		switch {
+		case expired.Load():
+			cleanup(expiredDesc, false)
+			return
 		case sErr == io.EOF:
-			cleanup(fmt.Sprintf("[%s] instance closed the connection", inst), false)
+			cleanup("instance closed the connection", false)
 			return
 		case sErr != nil:
-			cleanup(fmt.Sprintf("[%s] connection aborted - error reading from instance: %v", inst, sErr), true)
+			cleanup(fmt.Sprintf("connection aborted - error reading from instance: %v", sErr), true)
 			return
 		case cErr == io.EOF:
-			cleanup(fmt.Sprintf("[%s] client closed the connection", inst), false)
+			cleanup("client closed the connection", false)
 			return
 		case cErr != nil:
-			cleanup(fmt.Sprintf("[%s] connection aborted - error writing to client: %v", inst, sErr), true)
+			cleanup(fmt.Sprintf("connection aborted - error writing to client: %v", sErr), true)
 			return
 		}
 	}
@@ -42,9 +42,10 @@ func configureFUSE(c *Client, conf *Config) (*Client, error) {
 		return nil, err
 	}
 	c.fuseMount = fuseMount{
-		fuseDir:     conf.FUSEDir,
-		fuseTempDir: conf.FUSETempDir,
-		fuseSockets: map[string]socketSymlink{},
+		fuseDir:          conf.FUSEDir,
+		fuseTempDir:      conf.FUSETempDir,
+		fuseSockets:      map[string]socketSymlink{},
+		fuseMaxInstances: conf.FUSEMaxInstances,
 		// Use pointers for the following mutexes so fuseMount may be embedded
 		// as a value and support zero value lookups on fuseDir.
 		fuseMu:       &sync.Mutex{},
@@ -64,11 +65,14 @@ type fuseMount struct {
 	fuseMu *sync.Mutex
 	// fuseSockets is a map of instance connection name to socketMount and
 	// symlink.
-	fuseSockets  map[string]socketSymlink
-	fuseServerMu *sync.Mutex
-	fuseServer   *fuse.Server
-	fuseWg       *sync.WaitGroup
-	fuseExitCh   chan error
+	fuseSockets map[string]socketSymlink
+	// fuseMaxInstances is the maximum number of instances in fuseSockets. Zero
+	// means no limit.
+	fuseMaxInstances uint64
+	fuseServerMu     *sync.Mutex
+	fuseServer       *fuse.Server
+	fuseWg           *sync.WaitGroup
+	fuseExitCh       chan error
 
 	// Inode adds support for FUSE operations.
 	fs.Inode
@@ -116,6 +120,13 @@ func (c *Client) Lookup(_ context.Context, instance string, _ *fuse.EntryOut) (*
 	if l, ok := c.fuseSockets[instance]; ok {
 		return l.symlink.EmbeddedInode(), fs.OK
 	}
+	if c.fuseMaxInstances > 0 && uint64(len(c.fuseSockets)) >= c.fuseMaxInstances {
+		c.logger.Errorf(
+			"could not create socket for %q: max FUSE instances reached (max = %v)",
+			instance, c.fuseMaxInstances,
+		)
+		return nil, syscall.ENOENT
+	}
 
 	s, err := newSocketMount(
 		ctx, withUnixSocket(*c.conf, c.fuseTempDir),
@@ -149,7 +160,8 @@ func (c *Client) Lookup(_ context.Context, instance string, _ *fuse.EntryOut) (*
 
 	// Return a symlink that points to the actual Unix socket within the
 	// temporary directory. For Postgres, return a symlink that points to the
-	// directory which holds the ".s.PGSQL.5432" Unix socket.
+	// directory which holds the ".s.PGSQL.5432" Unix socket (or the suffix
+	// set with FUSESocketSuffix).
 	sl := &symlink{path: filepath.Join(c.fuseTempDir, instance)}
 	c.fuseSockets[instance] = socketSymlink{
 		socket:  s,
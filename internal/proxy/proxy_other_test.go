@@ -19,8 +19,11 @@ package proxy_test
 
 import (
 	"context"
+	"net"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/internal/proxy"
 )
@@ -50,3 +53,92 @@ func TestFuseClosesGracefully(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestClientReportsPortInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	in := &proxy.Config{
+		Addr: "127.0.0.1",
+		Port: ln.Addr().(*net.TCPAddr).Port,
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "projects/proj/locations/region/clusters/clust/instances/inst1"},
+		},
+	}
+	_, err = proxy.NewClient(context.Background(), &fakeDialer{}, testLogger, in)
+	if err == nil || !strings.Contains(err.Error(), "is already in use; choose another with --port") {
+		t.Fatalf("want port in use error, got = %v", err)
+	}
+}
+
+func TestClientRetriesBind(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Free the port while the Client is retrying.
+	time.AfterFunc(250*time.Millisecond, func() { ln.Close() })
+	in := &proxy.Config{
+		Addr:        "127.0.0.1",
+		Port:        ln.Addr().(*net.TCPAddr).Port,
+		BindRetries: 5,
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "projects/proj/locations/region/clusters/clust/instances/inst1"},
+		},
+	}
+	c, err := proxy.NewClient(context.Background(), &fakeDialer{}, testLogger, in)
+	if err != nil {
+		t.Fatalf("want error = nil after retrying bind, got = %v", err)
+	}
+	c.Close()
+}
+
+func TestClientBindRetriesStopWhenContextIsDone(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	in := &proxy.Config{
+		Addr:        "127.0.0.1",
+		Port:        ln.Addr().(*net.TCPAddr).Port,
+		BindRetries: 100,
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "projects/proj/locations/region/clusters/clust/instances/inst1"},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = proxy.NewClient(ctx, &fakeDialer{}, testLogger, in)
+	if err == nil {
+		t.Fatal("want error, got nil")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("want NewClient to return once the context is done, took %v", d)
+	}
+}
+
+func TestClientReportsPrivilegedPort(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root may bind to privileged ports")
+	}
+	in := &proxy.Config{
+		Addr: "127.0.0.1",
+		Port: 80,
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "projects/proj/locations/region/clusters/clust/instances/inst1"},
+		},
+	}
+	c, err := proxy.NewClient(context.Background(), &fakeDialer{}, testLogger, in)
+	if err == nil {
+		// Some systems allow unprivileged users to bind to low ports.
+		c.Close()
+		t.Skip("binding to port 80 is not restricted")
+	}
+	if !strings.Contains(err.Error(), "permission denied binding to privileged port 80") {
+		t.Fatalf("want privileged port error, got = %v", err)
+	}
+}
@@ -15,7 +15,13 @@
 package proxy_test
 
 import (
+	"context"
+	"io"
 	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/internal/proxy"
+	"github.com/Microsoft/go-winio"
 )
 
 func verifySocketPermissions(t *testing.T, addr string) {
@@ -23,3 +29,37 @@ func verifySocketPermissions(t *testing.T, addr string) {
 	// os.Stat. That operation is not supported on Windows.
 	// See https://github.com/microsoft/Windows-Containers/issues/97#issuecomment-887713195
 }
+
+func TestClientWithNamedPipe(t *testing.T) {
+	in := &proxy.Config{
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "projects/proj/locations/region/clusters/clust/instances/inst"},
+		},
+		NamedPipe: true,
+	}
+	c, err := proxy.NewClient(context.Background(), &echoDialer{}, testLogger, in)
+	if err != nil {
+		t.Fatalf("want error = nil, got = %v", err)
+	}
+	defer c.Close()
+	go c.Serve(context.Background(), func() {})
+
+	timeout := 5 * time.Second
+	conn, err := winio.DialPipe(proxy.NamedPipeName("proj.region.clust.inst"), &timeout)
+	if err != nil {
+		t.Fatalf("DialPipe(): %v", err)
+	}
+	defer conn.Close()
+
+	want := "hello"
+	if _, err := conn.Write([]byte(want)); err != nil {
+		t.Fatalf("Write(): %v", err)
+	}
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("Read(): %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("want = %q, got = %q", want, got)
+	}
+}
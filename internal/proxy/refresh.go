@@ -0,0 +1,161 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/alloydb"
+)
+
+// The connector has no hooks for certificate refreshes and reports them only
+// in its debug logs. These are the formats of the messages that conclude a
+// refresh.
+const (
+	// refreshFailedFormat reports a refresh that failed before or while
+	// requesting connection info.
+	refreshFailedFormat = "[%v] Connection info refresh operation failed, err = %v"
+	// certExpirationFormat reports the expiration of the certificate from a
+	// refresh. A failed background refresh reports the zero time.
+	certExpirationFormat = "[%v] Current certificate expiration = %v"
+)
+
+// errInvalidCertificate is recorded for a refresh that concluded without a
+// valid certificate.
+var errInvalidCertificate = errors.New("refresh did not produce a valid certificate")
+
+// refreshLogger is the connector's debug logger. It records the result of
+// each certificate refresh and logs failed refreshes, and forwards all
+// messages to the Proxy's logger when debug logging is enabled.
+type refreshLogger struct {
+	l     alloydb.Logger
+	debug bool
+	// status records the outcome of each refresh. It may be nil.
+	status *refreshStatus
+}
+
+// Debugf implements the connector's debug.Logger interface.
+func (r *refreshLogger) Debugf(format string, args ...interface{}) {
+	r.observe(format, args)
+	if r.debug {
+		r.l.Debugf(format, args...)
+	}
+}
+
+// observe records a refresh result if the message concludes a refresh.
+func (r *refreshLogger) observe(format string, args []interface{}) {
+	if len(args) != 2 {
+		return
+	}
+	inst := fmt.Sprint(args[0])
+	short, err := ShortInstURI(inst)
+	if err != nil {
+		short = inst
+	}
+	switch format {
+	case refreshFailedFormat:
+		r.l.Errorf("[%v] Certificate refresh failed: %v", short, args[1])
+		recordCertRefresh(context.Background(), inst, short, resultFailure)
+		rErr, ok := args[1].(error)
+		if !ok {
+			rErr = errors.New(fmt.Sprint(args[1]))
+		}
+		r.status.record(short, rErr)
+	case certExpirationFormat:
+		exp, err := time.Parse(time.RFC3339, fmt.Sprint(args[1]))
+		if err != nil || exp.Year() <= 1 {
+			r.l.Errorf("[%v] Certificate refresh failed", short)
+			recordCertRefresh(context.Background(), inst, short, resultFailure)
+			r.status.record(short, errInvalidCertificate)
+			return
+		}
+		recordCertRefresh(context.Background(), inst, short, resultSuccess)
+		r.status.record(short, nil)
+	}
+}
+
+// RefreshHealth reports the outcome of recent certificate refreshes for an
+// instance.
+type RefreshHealth struct {
+	// Instance is the short name of the instance, e.g.,
+	// project.region.cluster.instance.
+	Instance string
+	// LastSuccess is the time of the most recent successful refresh. It is
+	// the zero time if no refresh has succeeded.
+	LastSuccess time.Time
+	// LastFailure is the time of the most recent failed refresh. It is the
+	// zero time if no refresh has failed.
+	LastFailure time.Time
+	// LastError is the error of the most recent failed refresh.
+	LastError error
+}
+
+// Failing reports whether the most recent refresh failed.
+func (h RefreshHealth) Failing() bool {
+	return h.LastFailure.After(h.LastSuccess)
+}
+
+// refreshStatus records the outcome of the most recent certificate refreshes
+// of each instance. It outlives any one dialer, so that reloading
+// credentials keeps the history.
+type refreshStatus struct {
+	mu    sync.Mutex
+	insts map[string]*RefreshHealth
+}
+
+func newRefreshStatus() *refreshStatus {
+	return &refreshStatus{insts: make(map[string]*RefreshHealth)}
+}
+
+// record notes a refresh of the instance short that failed with err, or
+// succeeded if err is nil.
+func (s *refreshStatus) record(short string, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.insts[short]
+	if !ok {
+		h = &RefreshHealth{Instance: short}
+		s.insts[short] = h
+	}
+	if err != nil {
+		h.LastFailure = time.Now()
+		h.LastError = err
+		return
+	}
+	h.LastSuccess = time.Now()
+}
+
+// health returns the refresh health of each instance, ordered by name.
+func (s *refreshStatus) health() []RefreshHealth {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs := make([]RefreshHealth, 0, len(s.insts))
+	for _, h := range s.insts {
+		hs = append(hs, *h)
+	}
+	sort.Slice(hs, func(i, j int) bool { return hs[i].Instance < hs[j].Instance })
+	return hs
+}
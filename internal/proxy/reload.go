@@ -0,0 +1,96 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"cloud.google.com/go/alloydbconn"
+	"github.com/GoogleCloudPlatform/alloydb-auth-proxy/alloydb"
+)
+
+// dialerGeneration is a dialer along with its in-flight dials.
+type dialerGeneration struct {
+	alloydb.Dialer
+	wg sync.WaitGroup
+}
+
+// reloadableDialer is an alloydb.Dialer that may be replaced with a new
+// dialer while in use, e.g., to pick up a rotated service account key. The
+// connector reads credentials only when a dialer is created, so replacing the
+// dialer is the only way to change them. Connections already open are
+// unaffected.
+type reloadableDialer struct {
+	newDialer func() (alloydb.Dialer, error)
+
+	mu     sync.RWMutex
+	cur    *dialerGeneration
+	closed bool
+}
+
+// newReloadableDialer returns a reloadableDialer that uses newDialer to build
+// its initial dialer and to build a new one on each reload.
+func newReloadableDialer(newDialer func() (alloydb.Dialer, error)) (*reloadableDialer, error) {
+	d, err := newDialer()
+	if err != nil {
+		return nil, err
+	}
+	return &reloadableDialer{
+		newDialer: newDialer,
+		cur:       &dialerGeneration{Dialer: d},
+	}, nil
+}
+
+// Dial connects to the instance with the most recently created dialer.
+func (r *reloadableDialer) Dial(ctx context.Context, inst string, opts ...alloydbconn.DialOption) (net.Conn, error) {
+	r.mu.RLock()
+	g := r.cur
+	g.wg.Add(1)
+	r.mu.RUnlock()
+	defer g.wg.Done()
+	return g.Dial(ctx, inst, opts...)
+}
+
+// Close closes the current dialer.
+func (r *reloadableDialer) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return r.cur.Close()
+}
+
+// reload replaces the current dialer with a new one, and then closes the
+// previous dialer once its in-flight dials complete. On error, the current
+// dialer remains in use.
+func (r *reloadableDialer) reload() error {
+	d, err := r.newDialer()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		d.Close()
+		return errors.New("dialer is closed")
+	}
+	old := r.cur
+	r.cur = &dialerGeneration{Dialer: d}
+	r.mu.Unlock()
+	old.wg.Wait()
+	return old.Close()
+}
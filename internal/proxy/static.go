@@ -0,0 +1,193 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// staticInstanceInfo is the subset of an instance's static connection info
+// that the Proxy validates before handing the file to the connector.
+type staticInstanceInfo struct {
+	PEMCertificateChain []string `json:"pemCertificateChain"`
+	CACert              string   `json:"caCert"`
+}
+
+// validateStaticConnectionInfo reports the first problem with the static
+// connection info in data that would otherwise surface as an opaque error
+// when dialing: malformed JSON, missing keys, certificates that do not parse,
+// or a client certificate that has expired as of now.
+func validateStaticConnectionInfo(data []byte, now time.Time) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("malformed JSON: %v", err)
+	}
+	keys := make(map[string]string)
+	for _, k := range []string{"publicKey", "privateKey"} {
+		v, ok := raw[k]
+		if !ok {
+			return fmt.Errorf("missing %v", k)
+		}
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return fmt.Errorf("%v must be a string: %v", k, err)
+		}
+		if b, _ := pem.Decode([]byte(s)); b == nil {
+			return fmt.Errorf("%v is not PEM encoded", k)
+		}
+		keys[k] = s
+		delete(raw, k)
+	}
+	if len(raw) == 0 {
+		return errors.New("no instance connection info found")
+	}
+	for inst, v := range raw {
+		var info staticInstanceInfo
+		if err := json.Unmarshal(v, &info); err != nil {
+			return fmt.Errorf("instance %q: %v", inst, err)
+		}
+		if err := info.validate(keys["privateKey"], now); err != nil {
+			return fmt.Errorf("instance %q: %v", inst, err)
+		}
+	}
+	return nil
+}
+
+// validate checks the instance's certificates parse, match privateKey, and
+// have not expired.
+func (i staticInstanceInfo) validate(privateKey string, now time.Time) error {
+	if len(i.PEMCertificateChain) == 0 {
+		return errors.New("missing pemCertificateChain")
+	}
+	if i.CACert == "" {
+		return errors.New("missing caCert")
+	}
+	if _, err := parsePEMCert(i.CACert); err != nil {
+		return fmt.Errorf("invalid caCert: %v", err)
+	}
+	cert, err := parsePEMCert(i.PEMCertificateChain[0])
+	if err != nil {
+		return fmt.Errorf("invalid client certificate: %v", err)
+	}
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf(
+			"client certificate expired at %v, regenerate the static connection info",
+			cert.NotAfter.UTC().Format(time.RFC3339),
+		)
+	}
+	_, err = tls.X509KeyPair(
+		[]byte(strings.Join(i.PEMCertificateChain, "\n")), []byte(privateKey),
+	)
+	if err != nil {
+		return fmt.Errorf("client certificate does not match privateKey: %v", err)
+	}
+	return nil
+}
+
+// parsePEMCert parses the first PEM block in s as an X.509 certificate.
+func parsePEMCert(s string) (*x509.Certificate, error) {
+	b, _ := pem.Decode([]byte(s))
+	if b == nil {
+		return nil, errors.New("not PEM encoded")
+	}
+	return x509.ParseCertificate(b.Bytes)
+}
+
+// loadStaticConnectionInfo reads and validates each comma-separated file in
+// paths and merges them into a single static connection info document. All
+// files must share the same key pair, and an instance may appear in more than
+// one file only if its connection info is identical.
+func loadStaticConnectionInfo(paths string, now time.Time) ([]byte, error) {
+	var (
+		merged = make(map[string]json.RawMessage)
+		// source records the file that first set each key.
+		source = make(map[string]string)
+	)
+	for _, p := range strings.Split(paths, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateStaticConnectionInfo(data, now); err != nil {
+			return nil, fmt.Errorf("invalid static connection info %q: %v", p, err)
+		}
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("invalid static connection info %q: %v", p, err)
+		}
+		for k, v := range raw {
+			prev, ok := merged[k]
+			if !ok {
+				merged[k] = v
+				source[k] = p
+				continue
+			}
+			if !sameJSON(prev, v) {
+				return nil, fmt.Errorf(
+					"static connection info %q and %q disagree on %q",
+					source[k], p, k,
+				)
+			}
+		}
+	}
+	if len(merged) == 0 {
+		return nil, errors.New("no static connection info files provided")
+	}
+	return json.Marshal(merged)
+}
+
+// sameJSON reports whether a and b hold the same JSON value, ignoring
+// formatting.
+func sameJSON(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return string(ja) == string(jb)
+}
+
+// rewindReader is an io.Reader that starts over after reporting io.EOF. The
+// connector reads the static connection info to completion once for each
+// instance it dials, so a plain reader would be empty for every instance
+// after the first.
+type rewindReader struct {
+	data []byte
+	off  int
+}
+
+func (r *rewindReader) Read(p []byte) (int, error) {
+	if r.off >= len(r.data) {
+		r.off = 0
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[r.off:])
+	r.off += n
+	return n, nil
+}